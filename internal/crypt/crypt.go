@@ -0,0 +1,119 @@
+// Package crypt is used to encrypt and decrypt data at rest with a key
+// derived from a passphrase using scrypt and encrypted with AES-GCM.
+package crypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	marker  = "pm-creds-sealed"
+	version = 1
+	keyLen  = 32
+	saltLen = 16
+)
+
+// Default scrypt cost parameters.
+var (
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+)
+
+// envelope is the sealed format written to disk.
+type envelope struct {
+	Marker  string `json:"marker"`
+	Version int    `json:"version"`
+	KDF     string `json:"kdf"`
+	N       int    `json:"n"`
+	R       int    `json:"r"`
+	P       int    `json:"p"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+// Seal will encrypt plaintext with a key derived from passphrase and return
+// the sealed data.
+func Seal(passphrase string, plaintext []byte) ([]byte, error) {
+	env := &envelope{
+		Marker:  marker,
+		Version: version,
+		KDF:     "scrypt",
+		N:       scryptN,
+		R:       scryptR,
+		P:       scryptP,
+		Salt:    make([]byte, saltLen),
+	}
+	if _, err := rand.Read(env.Salt); err != nil {
+		return nil, fmt.Errorf("crypt: couldn't create salt. %w", err)
+	}
+
+	aead, err := newAEAD(passphrase, env)
+	if err != nil {
+		return nil, err
+	}
+
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return nil, fmt.Errorf("crypt: couldn't create nonce. %w", err)
+	}
+	env.Data = aead.Seal(nil, env.Nonce, plaintext, []byte(marker))
+
+	return json.MarshalIndent(env, "", "  ")
+}
+
+// Open will decrypt sealed with a key derived from passphrase and return the plaintext.
+func Open(passphrase string, sealed []byte) ([]byte, error) {
+	env := &envelope{}
+	if err := json.Unmarshal(sealed, env); err != nil || env.Marker != marker {
+		return nil, fmt.Errorf("crypt: data isn't sealed")
+	}
+	if env.Version != version || env.KDF != "scrypt" {
+		return nil, fmt.Errorf("crypt: unsupported version %d or kdf %q", env.Version, env.KDF)
+	}
+
+	aead, err := newAEAD(passphrase, env)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := aead.Open(nil, env.Nonce, env.Data, []byte(marker))
+	if err != nil {
+		return nil, fmt.Errorf("crypt: couldn't decrypt data. wrong passphrase?")
+	}
+
+	return plaintext, nil
+}
+
+// IsSealed returns true if data has been sealed by Seal.
+func IsSealed(data []byte) bool {
+	env := &envelope{}
+	return json.Unmarshal(data, env) == nil && env.Marker == marker
+}
+
+// newAEAD returns AES-GCM using a key derived from passphrase and the parameters in env.
+func newAEAD(passphrase string, env *envelope) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), env.Salt, env.N, env.R, env.P, keyLen)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: couldn't derive key. %w", err)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: couldn't create cipher. %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("crypt: couldn't create gcm. %w", err)
+	}
+
+	return aead, nil
+}
@@ -0,0 +1,31 @@
+package crypt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSealOpen(t *testing.T) {
+	scryptN = 1 << 10
+
+	sealed, err := Seal("passphrase", []byte("secret data"))
+	assert.NoError(t, err)
+	assert.True(t, IsSealed(sealed))
+	assert.NotContains(t, string(sealed), "secret data")
+
+	plaintext, err := Open("passphrase", sealed)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret data", string(plaintext))
+
+	_, err = Open("wrong", sealed)
+	assert.EqualError(t, err, "crypt: couldn't decrypt data. wrong passphrase?")
+
+	other, err := Seal("passphrase", []byte("secret data"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, sealed, other)
+
+	assert.False(t, IsSealed([]byte(`[profile]`)))
+	_, err = Open("passphrase", []byte(`{"key": "value"}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,229 @@
+// Package jwt is used to sign json web tokens and read the claims of tokens.
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	_ "crypto/sha256" // Registers the sha256 hash.
+	_ "crypto/sha512" // Registers the sha384 and sha512 hashes.
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+)
+
+// Algorithms supported.
+const (
+	RS256 = "RS256"
+	RS384 = "RS384"
+	RS512 = "RS512"
+	PS256 = "PS256"
+	PS384 = "PS384"
+	PS512 = "PS512"
+	ES256 = "ES256"
+	ES384 = "ES384"
+	ES512 = "ES512"
+	EdDSA = "EdDSA"
+)
+
+// hashes are the hash functions used by the algorithms.
+var hashes = map[string]crypto.Hash{
+	RS256: crypto.SHA256, RS384: crypto.SHA384, RS512: crypto.SHA512,
+	PS256: crypto.SHA256, PS384: crypto.SHA384, PS512: crypto.SHA512,
+	ES256: crypto.SHA256, ES384: crypto.SHA384, ES512: crypto.SHA512,
+}
+
+// curves are the elliptic curves required by the ecdsa algorithms.
+var curves = map[string]elliptic.Curve{
+	ES256: elliptic.P256(), ES384: elliptic.P384(), ES512: elliptic.P521(),
+}
+
+// Algorithm returns the default algorithm for key.
+func Algorithm(key crypto.Signer) (string, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return RS256, nil
+	case *ecdsa.PrivateKey:
+		for alg, curve := range curves {
+			if k.Curve == curve {
+				return alg, nil
+			}
+		}
+		return "", fmt.Errorf("jwt: unsupported curve %s", k.Curve.Params().Name)
+	case ed25519.PrivateKey:
+		return EdDSA, nil
+	default:
+		return "", fmt.Errorf("jwt: unsupported key type %T", key)
+	}
+}
+
+// Sign will create a token with header and claims signed by key using alg.
+// The alg and typ header fields are always set.
+func Sign(alg string, key crypto.Signer, header map[string]interface{}, claims map[string]interface{}) (string, error) {
+	h := map[string]interface{}{}
+	for k, v := range header {
+		h[k] = v
+	}
+	h["alg"] = alg
+	h["typ"] = "JWT"
+
+	rawHeader, err := json.Marshal(h)
+	if err != nil {
+		return "", fmt.Errorf("jwt: couldn't json marshal header. %w", err)
+	}
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("jwt: couldn't json marshal claims. %w", err)
+	}
+
+	unsigned := encode(rawHeader) + "." + encode(rawClaims)
+	signature, err := sign(alg, key, []byte(unsigned))
+	if err != nil {
+		return "", err
+	}
+
+	return unsigned + "." + encode(signature), nil
+}
+
+// Check returns an error if alg isn't supported or can't be used with key.
+// Only alg is checked if key is nil.
+func Check(alg string, key crypto.Signer) error {
+	if _, ok := hashes[alg]; !ok && alg != EdDSA {
+		return fmt.Errorf("jwt: unsupported algorithm %q", alg)
+	}
+	if key == nil {
+		return nil
+	}
+
+	if alg == EdDSA {
+		if _, ok := key.(ed25519.PrivateKey); !ok {
+			return fmt.Errorf("jwt: algorithm %s requires a ed25519 key", alg)
+		}
+		return nil
+	}
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		if alg[:2] != "RS" && alg[:2] != "PS" {
+			return fmt.Errorf("jwt: algorithm %s requires a ecdsa key", alg)
+		}
+	case *ecdsa.PrivateKey:
+		if curves[alg] != k.Curve {
+			return fmt.Errorf("jwt: algorithm %s requires a ecdsa key with curve %s", alg, curveName(alg))
+		}
+	default:
+		return fmt.Errorf("jwt: algorithm %s doesn't support key type %T", alg, key)
+	}
+	return nil
+}
+
+// sign returns the signature of data using alg.
+func sign(alg string, key crypto.Signer, data []byte) ([]byte, error) {
+	if err := Check(alg, key); err != nil {
+		return nil, err
+	}
+	if alg == EdDSA {
+		return ed25519.Sign(key.(ed25519.PrivateKey), data), nil
+	}
+
+	hash := hashes[alg]
+	h := hash.New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		var signature []byte
+		var err error
+		switch alg[:2] {
+		case "RS":
+			signature, err = rsa.SignPKCS1v15(rand.Reader, k, hash, digest)
+		default:
+			signature, err = rsa.SignPSS(rand.Reader, k, hash, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+		if err != nil {
+			return nil, fmt.Errorf("jwt: couldn't sign token. %w", err)
+		}
+		return signature, nil
+
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest)
+		if err != nil {
+			return nil, fmt.Errorf("jwt: couldn't sign token. %w", err)
+		}
+		// The signature is r and s as fixed size big endian integers, see rfc 7518 section 3.4.
+		size := (k.Curve.Params().BitSize + 7) / 8
+		signature := make([]byte, 2*size)
+		r.FillBytes(signature[:size])
+		s.FillBytes(signature[size:])
+		return signature, nil
+
+	default:
+		return nil, fmt.Errorf("jwt: algorithm %s doesn't support key type %T", alg, key)
+	}
+}
+
+// curveName returns the name of the curve required by alg.
+func curveName(alg string) string {
+	if curve, ok := curves[alg]; ok {
+		return curve.Params().Name
+	}
+	return "none"
+}
+
+// Claims returns the claims of token without verifying its signature.
+func Claims(token string) (map[string]interface{}, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("jwt: token isn't in format header.claims.signature")
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("jwt: couldn't decode claims. %w", err)
+	}
+
+	claims := map[string]interface{}{}
+	if err := json.Unmarshal(raw, &claims); err != nil {
+		return nil, fmt.Errorf("jwt: couldn't json unmarshal claims. %w", err)
+	}
+
+	return claims, nil
+}
+
+// ParsePrivateKey will parse a pem encoded pkcs1, sec1 or pkcs8 private key.
+func ParsePrivateKey(raw []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("jwt: no pem encoded private key found")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("jwt: couldn't parse private key. %w", err)
+	}
+
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("jwt: unsupported private key type %T", key)
+	}
+	return signer, nil
+}
+
+// encode returns raw base64 url encoded without padding.
+func encode(raw []byte) string {
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
@@ -0,0 +1,146 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	for _, fn := range []string{"./testdata/rsa-pkcs1.pem", "./testdata/rsa-pkcs8.pem"} {
+		raw, err := os.ReadFile(fn)
+		assert.NoError(t, err)
+		key, err := ParsePrivateKey(raw)
+		assert.NoError(t, err)
+
+		token, err := Sign(RS256, key, map[string]interface{}{"kid": "key-id", "alg": "none"}, map[string]interface{}{"iss": "issuer"})
+		assert.NoError(t, err)
+
+		parts := strings.Split(token, ".")
+		assert.Len(t, parts, 3)
+
+		rawHeader, err := base64.RawURLEncoding.DecodeString(parts[0])
+		assert.NoError(t, err)
+		header := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(rawHeader, &header))
+		assert.Equal(t, map[string]interface{}{"alg": "RS256", "typ": "JWT", "kid": "key-id"}, header)
+
+		signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+		assert.NoError(t, err)
+		digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+		assert.NoError(t, rsa.VerifyPKCS1v15(key.Public().(*rsa.PublicKey), crypto.SHA256, digest[:], signature))
+
+		claims, err := Claims(token)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{"iss": "issuer"}, claims)
+	}
+}
+
+// verify returns error if the signature of token isn't valid for key using alg.
+func verify(t *testing.T, alg string, key crypto.PublicKey, token string) error {
+	parts := strings.Split(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	assert.NoError(t, err)
+	data := []byte(parts[0] + "." + parts[1])
+
+	if alg == EdDSA {
+		if !ed25519.Verify(key.(ed25519.PublicKey), data, signature) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+
+	h := hashes[alg].New()
+	h.Write(data)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[:2] == "PS" {
+			return rsa.VerifyPSS(k, hashes[alg], digest, signature, nil)
+		}
+		return rsa.VerifyPKCS1v15(k, hashes[alg], digest, signature)
+	case *ecdsa.PublicKey:
+		size := len(signature) / 2
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported key")
+}
+
+func TestAlgorithms(t *testing.T) {
+	for fn, algs := range map[string][]string{
+		"./testdata/rsa-pkcs8.pem": {RS256, RS384, RS512, PS256, PS384, PS512},
+		"./testdata/ec-p256.pem":   {ES256},
+		"./testdata/ec-p384.pem":   {ES384},
+		"./testdata/ed25519.pem":   {EdDSA},
+	} {
+		raw, err := os.ReadFile(fn)
+		assert.NoError(t, err)
+		key, err := ParsePrivateKey(raw)
+		assert.NoError(t, err, fn)
+
+		alg, err := Algorithm(key)
+		assert.NoError(t, err)
+		assert.Equal(t, algs[0], alg, fn)
+
+		for _, alg := range algs {
+			token, err := Sign(alg, key, nil, map[string]interface{}{"sub": "subject"})
+			assert.NoError(t, err, alg)
+			assert.NoError(t, verify(t, alg, key.Public(), token), alg)
+		}
+
+		for _, alg := range []string{RS256, ES256, ES512, EdDSA} {
+			if alg == algs[0] {
+				continue
+			}
+			_, err := Sign(alg, key, nil, nil)
+			assert.Error(t, err, "%s %s", fn, alg)
+		}
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	_, err = Sign(RS256, ecKey, nil, nil)
+	assert.Error(t, err)
+
+	raw, err := os.ReadFile("./testdata/rsa-pkcs1.pem")
+	assert.NoError(t, err)
+	key, err := ParsePrivateKey(raw)
+	assert.NoError(t, err)
+	_, err = Sign("HS256", key, nil, nil)
+	assert.Error(t, err)
+
+	// Algorithms can be checked without a key.
+	assert.NoError(t, Check(PS256, nil))
+	assert.NoError(t, Check(EdDSA, nil))
+	assert.EqualError(t, Check("HS256", nil), `jwt: unsupported algorithm "HS256"`)
+	assert.EqualError(t, Check(ES256, key), "jwt: algorithm ES256 requires a ecdsa key")
+	assert.EqualError(t, Check(EdDSA, key), "jwt: algorithm EdDSA requires a ed25519 key")
+
+	_, err = ParsePrivateKey([]byte("not a key"))
+	assert.Error(t, err)
+
+	for _, token := range []string{"", "a.b", "a.!!!.c", "a." + base64.RawURLEncoding.EncodeToString([]byte("[]")) + ".c"} {
+		_, err = Claims(token)
+		assert.Error(t, err, token)
+	}
+}
@@ -0,0 +1,120 @@
+// Package oauth is used by providers to request tokens from oauth2 token endpoints.
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Error codes returned by token endpoints, see rfc 6749 section 5.2.
+const (
+	ErrInvalidGrant  = "invalid_grant"
+	ErrInvalidClient = "invalid_client"
+)
+
+// Error is an error response from a token endpoint.
+type Error struct {
+	TokenURL    string
+	Code        string
+	Description string
+}
+
+// Error returns the error code and description.
+func (e *Error) Error() string {
+	if e.Description != "" {
+		return fmt.Sprintf("oauth: token request to %q failed. %s: %s", e.TokenURL, e.Code, e.Description)
+	}
+	return fmt.Sprintf("oauth: token request to %q failed. %s", e.TokenURL, e.Code)
+}
+
+// IsCode returns true if err is an error response from a token endpoint with code.
+func IsCode(err error, code string) bool {
+	var oauthErr *Error
+	return errors.As(err, &oauthErr) && oauthErr.Code == code
+}
+
+// Token is a token returned by a token endpoint.
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+
+	// Expires is when the access token expires. Zero if it doesn't expire.
+	Expires time.Time `json:"-"`
+}
+
+// Payload returns the token as fields returned by providers.
+func (t *Token) Payload() map[string]interface{} {
+	payload := map[string]interface{}{"accessToken": t.AccessToken, "tokenType": t.TokenType}
+	if t.IDToken != "" {
+		payload["idToken"] = t.IDToken
+	}
+	if t.Scope != "" {
+		payload["scope"] = t.Scope
+	}
+	if !t.Expires.IsZero() {
+		payload["expiration"] = t.Expires.UTC().Format(time.RFC3339)
+	}
+	return payload
+}
+
+// Request will post form to the token endpoint tokenURL and return the token.
+func Request(client *http.Client, tokenURL string, form url.Values, header http.Header) (*Token, error) {
+	req, err := http.NewRequest(http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("oauth: couldn't create request to %q. %w", tokenURL, err)
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: couldn't make request to %q. %w", tokenURL, err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("oauth: couldn't read response from %q. %w", tokenURL, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		oauthErr := &struct {
+			Error       string `json:"error"`
+			Description string `json:"error_description"`
+		}{}
+		if json.Unmarshal(raw, oauthErr) == nil && oauthErr.Error != "" {
+			return nil, &Error{TokenURL: tokenURL, Code: oauthErr.Error, Description: oauthErr.Description}
+		}
+		return nil, fmt.Errorf("oauth: token request to %q failed. %s", tokenURL, res.Status)
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal(raw, token); err != nil {
+		return nil, fmt.Errorf("oauth: couldn't json unmarshal response from %q. %w", tokenURL, err)
+	}
+	if token.AccessToken == "" && token.IDToken == "" {
+		return nil, fmt.Errorf("oauth: no access token returned from %q", tokenURL)
+	}
+	if token.TokenType == "" && token.AccessToken != "" {
+		token.TokenType = "Bearer"
+	}
+	if token.ExpiresIn > 0 {
+		token.Expires = start.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+
+	return token, nil
+}
@@ -0,0 +1,68 @@
+package oauth
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequest(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.PostForm.Get("client_id") {
+		case "client":
+			assert.Equal(t, "application/x-www-form-urlencoded", r.Header.Get("Content-Type"))
+			assert.Equal(t, "value", r.Header.Get("X-Custom"))
+			fmt.Fprint(w, `{"access_token":"token","token_type":"Bearer","expires_in":3600,"scope":"read"}`)
+		case "no-expiry":
+			fmt.Fprint(w, `{"access_token":"token"}`)
+		case "id-token":
+			fmt.Fprint(w, `{"id_token":"id"}`)
+		case "empty":
+			fmt.Fprint(w, `{}`)
+		case "invalid":
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"bad secret"}`)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	token, err := Request(server.Client(), server.URL, url.Values{"client_id": {"client"}}, http.Header{"X-Custom": {"value"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "token", token.AccessToken)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), token.Expires, time.Minute)
+	assert.Equal(t, map[string]interface{}{
+		"accessToken": "token",
+		"tokenType":   "Bearer",
+		"scope":       "read",
+		"expiration":  token.Expires.UTC().Format(time.RFC3339),
+	}, token.Payload())
+
+	token, err = Request(server.Client(), server.URL, url.Values{"client_id": {"no-expiry"}}, nil)
+	assert.NoError(t, err)
+	assert.True(t, token.Expires.IsZero())
+	assert.Equal(t, map[string]interface{}{"accessToken": "token", "tokenType": "Bearer"}, token.Payload())
+
+	token, err = Request(server.Client(), server.URL, url.Values{"client_id": {"id-token"}}, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "id", token.IDToken)
+	assert.Equal(t, "", token.TokenType)
+
+	_, err = Request(server.Client(), server.URL, url.Values{"client_id": {"invalid"}}, nil)
+	assert.EqualError(t, err, fmt.Sprintf("oauth: token request to %q failed. invalid_client: bad secret", server.URL))
+	assert.True(t, IsCode(fmt.Errorf("wrapped. %w", err), ErrInvalidClient))
+	assert.False(t, IsCode(err, ErrInvalidGrant))
+
+	for _, client := range []string{"empty", "error"} {
+		_, err = Request(server.Client(), server.URL, url.Values{"client_id": {client}}, nil)
+		assert.Error(t, err)
+		assert.False(t, IsCode(err, ErrInvalidClient))
+	}
+}
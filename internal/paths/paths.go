@@ -12,6 +12,16 @@ func ProvidersFile(cfgDir string) string {
 	return filepath.Join(cfgDir, "providers.toml")
 }
 
+// SecretsFile returns the absolute path to the default secrets file based on cfgDir.
+func SecretsFile(cfgDir string) string {
+	return filepath.Join(cfgDir, "secrets.toml")
+}
+
+// TokensFile returns the absolute path to the refresh tokens file of provider based on cfgDir.
+func TokensFile(cfgDir string, provider string) string {
+	return filepath.Join(cfgDir, provider+"-tokens.json")
+}
+
 // CertsDir returns the certificate directory.
 func CertsDir(cfgDir string) string {
 	return filepath.Join(cfgDir, "certs")
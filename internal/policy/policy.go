@@ -0,0 +1,265 @@
+// Package policy decides how requests for credentials are approved using
+// ordered rules. The first rule matching a request decides the action.
+package policy
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Actions a rule can have.
+const (
+	// Allow approves the request without asking.
+	Allow = "allow"
+	// Prompt asks for approval.
+	Prompt = "prompt"
+	// WarnPrompt asks for approval with a warning.
+	WarnPrompt = "warn-prompt"
+	// Deny denies the request without asking.
+	Deny = "deny"
+)
+
+// timeFormat is the format of the start and end of time ranges.
+const timeFormat = "15:04"
+
+// Rule matches requests and decides the action for them. All conditions that are set
+// must match, conditions that aren't set matches all requests.
+type Rule struct {
+	Name string `mapstructure:"name"`
+	// Providers and Profiles are glob patterns where * matches any characters.
+	Providers []string `mapstructure:"providers"`
+	Profiles  []string `mapstructure:"profiles"`
+	// ProfileRegex is a regular expression the profile must match.
+	ProfileRegex string `mapstructure:"profile-regex"`
+	// Clients are glob patterns for the common name of the client certificate.
+	Clients    []string `mapstructure:"clients"`
+	UserAgents []string `mapstructure:"user-agents"`
+	// Time is the time of day in format "09:00-17:00". Ranges can span midnight.
+	Time   string `mapstructure:"time"`
+	Action string `mapstructure:"action"`
+
+	providers    []*regexp.Regexp
+	profiles     []*regexp.Regexp
+	profileRegex *regexp.Regexp
+	clients      []*regexp.Regexp
+	userAgents   []*regexp.Regexp
+	start, end   time.Duration
+}
+
+// Request is a request for credentials to evaluate.
+type Request struct {
+	Provider  string
+	Profile   string
+	Client    string
+	UserAgent string
+	Time      time.Time
+}
+
+// Decision is the result of evaluating a request.
+type Decision struct {
+	Action string
+	// Rule is the name of the rule that matched, empty if the default action was used.
+	Rule string
+}
+
+// Step is the result of evaluating a single rule. Reason explains why the rule didn't
+// match and is empty for the rule that matched.
+type Step struct {
+	Rule   string
+	Action string
+	Reason string
+}
+
+// Policy contains the ordered rules and the action used if no rule matches.
+type Policy struct {
+	rules         []*Rule
+	defaultAction string
+}
+
+// New returns a new policy with rules evaluated in order. defaultAction is used
+// if no rule matches, if empty prompt is used.
+func New(rules []Rule, defaultAction string) (*Policy, error) {
+	if defaultAction == "" {
+		defaultAction = Prompt
+	}
+	if !validAction(defaultAction) {
+		return nil, fmt.Errorf("policy: invalid default action %q", defaultAction)
+	}
+
+	p := &Policy{defaultAction: defaultAction}
+	for i := range rules {
+		rule := rules[i]
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule %d", i+1)
+		}
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("policy: couldn't parse rule %q. %w", rule.Name, err)
+		}
+		p.rules = append(p.rules, &rule)
+	}
+
+	return p, nil
+}
+
+// Legacy returns rules for the profiles-approve, profiles-warn and profiles-deny lists. A profile
+// matches if it starts or ends with any of the patterns. Denied profiles are matched first.
+func Legacy(approve []string, warn []string, deny []string) []Rule {
+	rules := []Rule{}
+	for _, legacy := range []struct {
+		name     string
+		patterns []string
+		action   string
+	}{
+		{name: "profiles-deny", patterns: deny, action: Deny},
+		{name: "profiles-approve", patterns: approve, action: Allow},
+		{name: "profiles-warn", patterns: warn, action: WarnPrompt},
+	} {
+		if len(legacy.patterns) == 0 {
+			continue
+		}
+
+		quoted := []string{}
+		for _, pattern := range legacy.patterns {
+			quoted = append(quoted, regexp.QuoteMeta(pattern))
+		}
+		alt := strings.Join(quoted, "|")
+		rules = append(rules, Rule{
+			Name:         legacy.name,
+			ProfileRegex: fmt.Sprintf("^(%s)|(%s)$", alt, alt),
+			Action:       legacy.action,
+		})
+	}
+
+	return rules
+}
+
+// Evaluate returns the decision for req.
+func (p *Policy) Evaluate(req Request) Decision {
+	for _, rule := range p.rules {
+		if rule.reason(req) == "" {
+			return Decision{Action: rule.Action, Rule: rule.Name}
+		}
+	}
+	return Decision{Action: p.defaultAction}
+}
+
+// Explain returns the steps evaluated for req up to and including the rule that matched,
+// and the decision.
+func (p *Policy) Explain(req Request) ([]Step, Decision) {
+	steps := []Step{}
+	for _, rule := range p.rules {
+		reason := rule.reason(req)
+		steps = append(steps, Step{Rule: rule.Name, Action: rule.Action, Reason: reason})
+		if reason == "" {
+			return steps, Decision{Action: rule.Action, Rule: rule.Name}
+		}
+	}
+	return steps, Decision{Action: p.defaultAction}
+}
+
+// compile will validate the rule and compile its patterns.
+func (r *Rule) compile() error {
+	if !validAction(r.Action) {
+		return fmt.Errorf("invalid action %q", r.Action)
+	}
+
+	r.providers, r.profiles = globs(r.Providers), globs(r.Profiles)
+	r.clients, r.userAgents = globs(r.Clients), globs(r.UserAgents)
+
+	if r.ProfileRegex != "" {
+		re, err := regexp.Compile(r.ProfileRegex)
+		if err != nil {
+			return fmt.Errorf("couldn't parse profile-regex %q. %w", r.ProfileRegex, err)
+		}
+		r.profileRegex = re
+	}
+
+	if r.Time != "" {
+		times := strings.SplitN(r.Time, "-", 2)
+		if len(times) != 2 {
+			return fmt.Errorf("time %q isn't in format %q", r.Time, "15:04-15:04")
+		}
+		start, err := time.Parse(timeFormat, strings.TrimSpace(times[0]))
+		if err != nil {
+			return fmt.Errorf("couldn't parse time %q. %w", r.Time, err)
+		}
+		end, err := time.Parse(timeFormat, strings.TrimSpace(times[1]))
+		if err != nil {
+			return fmt.Errorf("couldn't parse time %q. %w", r.Time, err)
+		}
+		r.start, r.end = sinceMidnight(start), sinceMidnight(end)
+	}
+
+	return nil
+}
+
+// reason returns why req doesn't match the rule, or an empty string if it matches.
+func (r *Rule) reason(req Request) string {
+	switch {
+	case !matchAny(r.providers, req.Provider):
+		return fmt.Sprintf("provider %q doesn't match %q", req.Provider, r.Providers)
+	case !matchAny(r.profiles, req.Profile):
+		return fmt.Sprintf("profile %q doesn't match %q", req.Profile, r.Profiles)
+	case r.profileRegex != nil && !r.profileRegex.MatchString(req.Profile):
+		return fmt.Sprintf("profile %q doesn't match regex %q", req.Profile, r.ProfileRegex)
+	case !matchAny(r.clients, req.Client):
+		return fmt.Sprintf("client %q doesn't match %q", req.Client, r.Clients)
+	case !matchAny(r.userAgents, req.UserAgent):
+		return fmt.Sprintf("user agent %q doesn't match %q", req.UserAgent, r.UserAgents)
+	case r.Time != "" && !r.within(req.Time):
+		return fmt.Sprintf("time %s isn't within %s", req.Time.Format(timeFormat), r.Time)
+	}
+	return ""
+}
+
+// within returns true if the time of day of t is within the time range of the rule.
+// The start is inclusive and the end exclusive.
+func (r *Rule) within(t time.Time) bool {
+	now := sinceMidnight(t)
+	if r.start <= r.end {
+		return now >= r.start && now < r.end
+	}
+	return now >= r.start || now < r.end
+}
+
+// globs returns patterns as anchored regular expressions where * matches any
+// characters and ? matches a single character.
+func globs(patterns []string) []*regexp.Regexp {
+	res := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		quoted := regexp.QuoteMeta(pattern)
+		quoted = strings.Replace(quoted, `\*`, ".*", -1)
+		quoted = strings.Replace(quoted, `\?`, ".", -1)
+		res = append(res, regexp.MustCompile("^"+quoted+"$"))
+	}
+	return res
+}
+
+// matchAny returns true if str matches any of res or if res is empty.
+func matchAny(res []*regexp.Regexp, str string) bool {
+	if len(res) == 0 {
+		return true
+	}
+	for _, re := range res {
+		if re.MatchString(str) {
+			return true
+		}
+	}
+	return false
+}
+
+// sinceMidnight returns the time of day of t.
+func sinceMidnight(t time.Time) time.Duration {
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+}
+
+// validAction returns true if action is one of the supported actions.
+func validAction(action string) bool {
+	switch action {
+	case Allow, Prompt, WarnPrompt, Deny:
+		return true
+	}
+	return false
+}
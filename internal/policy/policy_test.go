@@ -0,0 +1,118 @@
+package policy
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func at(clock string) time.Time {
+	t, _ := time.Parse(timeFormat, clock)
+	return t
+}
+
+func TestEvaluate(t *testing.T) {
+	p, err := New([]Rule{
+		{Name: "night", Providers: []string{"aws"}, Time: "22:00-06:00", Action: Deny},
+		{Name: "prod", Providers: []string{"aws*"}, Profiles: []string{"*-prod", "prod-*"}, Action: WarnPrompt},
+		{Name: "ci", Clients: []string{"ci-*"}, UserAgents: []string{"PostmanRuntime/*"}, Action: Allow},
+		{ProfileRegex: `^sandbox-\d+$`, Action: Allow},
+	}, "")
+	assert.NoError(t, err)
+
+	for name, test := range map[string]struct {
+		req      Request
+		expected Decision
+	}{
+		"night": {
+			req:      Request{Provider: "aws", Profile: "dev", Time: at("23:30")},
+			expected: Decision{Action: Deny, Rule: "night"},
+		},
+		"early morning": {
+			req:      Request{Provider: "aws", Profile: "dev", Time: at("05:59")},
+			expected: Decision{Action: Deny, Rule: "night"},
+		},
+		"day": {
+			req:      Request{Provider: "aws", Profile: "dev", Time: at("06:00")},
+			expected: Decision{Action: Prompt},
+		},
+		"prod": {
+			req:      Request{Provider: "aws-sso", Profile: "team-prod", Time: at("12:00")},
+			expected: Decision{Action: WarnPrompt, Rule: "prod"},
+		},
+		"ci": {
+			req:      Request{Provider: "vault", Profile: "db", Client: "ci-runner", UserAgent: "PostmanRuntime/7.29.0"},
+			expected: Decision{Action: Allow, Rule: "ci"},
+		},
+		"ci wrong user agent": {
+			req:      Request{Provider: "vault", Profile: "db", Client: "ci-runner", UserAgent: "curl/7.79.1"},
+			expected: Decision{Action: Prompt},
+		},
+		"regex": {
+			req:      Request{Provider: "vault", Profile: "sandbox-12"},
+			expected: Decision{Action: Allow, Rule: "rule 4"},
+		},
+		"regex no match": {
+			req:      Request{Provider: "vault", Profile: "sandbox-prod"},
+			expected: Decision{Action: Prompt},
+		},
+	} {
+		assert.Equal(t, test.expected, p.Evaluate(test.req), name)
+	}
+}
+
+func TestExplain(t *testing.T) {
+	p, err := New([]Rule{
+		{Name: "office", Time: "09:00-17:00", Action: Allow},
+		{Name: "prod", Profiles: []string{"*prod*"}, Action: Deny},
+	}, Deny)
+	assert.NoError(t, err)
+
+	steps, decision := p.Explain(Request{Provider: "aws", Profile: "dev", Time: at("18:00")})
+	assert.Equal(t, Decision{Action: Deny}, decision)
+	assert.Equal(t, []Step{
+		{Rule: "office", Action: Allow, Reason: "time 18:00 isn't within 09:00-17:00"},
+		{Rule: "prod", Action: Deny, Reason: `profile "dev" doesn't match ["*prod*"]`},
+	}, steps)
+
+	steps, decision = p.Explain(Request{Provider: "aws", Profile: "dev", Time: at("09:00")})
+	assert.Equal(t, Decision{Action: Allow, Rule: "office"}, decision)
+	assert.Equal(t, []Step{{Rule: "office", Action: Allow}}, steps)
+}
+
+func TestLegacy(t *testing.T) {
+	p, err := New(Legacy([]string{"-dev", "dev-"}, []string{"-prod"}, []string{"root", "a.b"}), "")
+	assert.NoError(t, err)
+
+	for profile, expected := range map[string]Decision{
+		"team-dev":  {Action: Allow, Rule: "profiles-approve"},
+		"dev-team":  {Action: Allow, Rule: "profiles-approve"},
+		"team-prod": {Action: WarnPrompt, Rule: "profiles-warn"},
+		"root-dev":  {Action: Deny, Rule: "profiles-deny"},
+		"axb":       {Action: Prompt},
+		"a.b-team":  {Action: Deny, Rule: "profiles-deny"},
+		"team":      {Action: Prompt},
+	} {
+		assert.Equal(t, expected, p.Evaluate(Request{Provider: "aws", Profile: profile}), profile)
+	}
+
+	assert.Empty(t, Legacy(nil, nil, nil))
+}
+
+func TestErrors(t *testing.T) {
+	for name, rules := range map[string][]Rule{
+		"action":      {{Action: "maybe"}},
+		"regex":       {{ProfileRegex: "(", Action: Allow}},
+		"time format": {{Time: "09:00", Action: Allow}},
+		"time":        {{Time: "09:00-25:00", Action: Allow}},
+	} {
+		_, err := New(rules, "")
+		assert.Error(t, err, name)
+	}
+
+	_, err := New(nil, "maybe")
+	assert.EqualError(t, err, `policy: invalid default action "maybe"`)
+	_, err = New([]Rule{{Name: "bad", Action: "maybe"}}, "")
+	assert.EqualError(t, err, `policy: couldn't parse rule "bad". invalid action "maybe"`)
+}
@@ -0,0 +1,108 @@
+// Package process is used to run external commands that provides credentials
+// and returns what they wrote to stdout.
+package process
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+var rt = runtime.GOOS
+
+// Command is an external command that can be run.
+type Command struct {
+	// Args is the command and its arguments.
+	Args []string
+	// Env is added to the environment of pm-creds.
+	Env []string
+	// Dir is the working directory. Uses the current directory if empty.
+	Dir string
+	// Stdin is written to the command's stdin.
+	Stdin []byte
+	// Timeout is how long the command may run. No timeout if zero.
+	Timeout time.Duration
+	// Context kills the command when it's done. Optional.
+	Context context.Context
+}
+
+// Run will run the command and return what it wrote to stdout. If the command fails
+// or times out the returned error will include what it wrote to stderr.
+func (c *Command) Run() ([]byte, error) {
+	if len(c.Args) == 0 || c.Args[0] == "" {
+		return nil, fmt.Errorf("process: no command to run")
+	}
+
+	ctx, cancel := c.Context, func() {}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if c.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+	}
+	defer cancel()
+
+	stdout, stderr := &bytes.Buffer{}, &bytes.Buffer{}
+	cmd := exec.Command(c.Args[0], c.Args[1:]...)
+	cmd.Env = append(os.Environ(), c.Env...)
+	cmd.Dir = c.Dir
+	cmd.Stdin = bytes.NewReader(c.Stdin)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("process: couldn't start command %q. %w", c.Args[0], err)
+	}
+
+	// Kill the command and any processes it started if it times out. exited is set as soon as
+	// Wait returns so that a process that has already been reaped is never killed.
+	mu, exited, done := &sync.Mutex{}, false, make(chan struct{})
+	go func() {
+		select {
+		case <-done:
+		case <-ctx.Done():
+			mu.Lock()
+			defer mu.Unlock()
+			if !exited {
+				kill(cmd)
+			}
+		}
+	}()
+
+	err := cmd.Wait()
+	mu.Lock()
+	exited = true
+	mu.Unlock()
+	close(done)
+
+	if err != nil {
+		switch {
+		case c.Context != nil && c.Context.Err() != nil:
+			err = c.Context.Err()
+		case errors.Is(ctx.Err(), context.DeadlineExceeded):
+			err = fmt.Errorf("timed out after %s", c.Timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("process: command %q failed. %w: %s", c.Args[0], err, msg)
+		}
+		return nil, fmt.Errorf("process: command %q failed. %w", c.Args[0], err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// Shell returns the arguments needed to run line in the shell of the current os.
+func Shell(line string) []string {
+	if rt == "windows" {
+		return []string{"cmd.exe", "/C", line}
+	}
+	return []string{"sh", "-c", line}
+}
@@ -0,0 +1,75 @@
+package process
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var tests = []struct {
+	cmd    *Command
+	output string
+	err    string
+}{
+	{
+		cmd:    &Command{Args: []string{"echo", "hello"}},
+		output: "hello\n",
+	},
+	{
+		cmd:    &Command{Args: Shell("echo $PM_CREDS_TEST"), Env: []string{"PM_CREDS_TEST=env"}},
+		output: "env\n",
+	},
+	{
+		cmd:    &Command{Args: []string{"pwd"}, Dir: "/"},
+		output: "/\n",
+	},
+	{
+		cmd:    &Command{Args: []string{"cat"}, Stdin: []byte("stdin")},
+		output: "stdin",
+	},
+	{
+		cmd: &Command{Args: Shell("echo broken >&2; exit 3")},
+		err: `process: command "sh" failed. exit status 3: broken`,
+	},
+	{
+		cmd: &Command{Args: []string{"sleep", "5"}, Timeout: 50 * time.Millisecond},
+		err: `process: command "sleep" failed. timed out after 50ms`,
+	},
+	{
+		cmd: &Command{Args: []string{"sleep", "5"}, Context: cancelled()},
+		err: `process: command "sleep" failed. context canceled`,
+	},
+	{
+		cmd: &Command{},
+		err: "process: no command to run",
+	},
+}
+
+// cancelled returns a context that is already cancelled.
+func cancelled() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}
+
+func TestRun(t *testing.T) {
+	for _, test := range tests {
+		output, err := test.cmd.Run()
+		if test.err != "" {
+			assert.EqualError(t, err, test.err)
+			continue
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, test.output, string(output))
+	}
+}
+
+func TestShell(t *testing.T) {
+	assert.Equal(t, []string{"sh", "-c", "echo hi"}, Shell("echo hi"))
+
+	rt = "windows"
+	assert.Equal(t, []string{"cmd.exe", "/C", "echo hi"}, Shell("echo hi"))
+	rt = "linux"
+}
@@ -0,0 +1,14 @@
+//go:build windows
+// +build windows
+
+package process
+
+import "os/exec"
+
+// setProcessGroup does nothing on windows.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// kill will kill the process of cmd.
+func kill(cmd *exec.Cmd) {
+	cmd.Process.Kill()
+}
@@ -6,6 +6,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -18,15 +20,39 @@ func Create(name string, raw map[string]interface{}) (*Provider, error) {
 	data := &struct {
 		Credentials []string `mapstructure:"credentials"`
 		Configs     []string `mapstructure:"configs"`
-	}{}
+		StsEndpoint string   `mapstructure:"sts-endpoint"`
+		StsRegion   string   `mapstructure:"sts-region"`
+		SsoEndpoint string   `mapstructure:"sso-endpoint"`
+		SsoCacheDir string   `mapstructure:"sso-cache-dir"`
+
+		ProcessTimeout string `mapstructure:"credential-process-timeout"`
+	}{StsRegion: defaultStsRegion, SsoCacheDir: defaultSsoCacheDir()}
 	if err := mapstructure.Decode(raw, data); err != nil {
 		return nil, fmt.Errorf("aws: couldn't decode raw to data for %q. %w", name, err)
 	}
 
+	processTimeout := defaultProcessTimeout
+	if data.ProcessTimeout != "" {
+		timeout, err := time.ParseDuration(data.ProcessTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("aws: couldn't parse %s for %q. %w", "credential-process-timeout", name, err)
+		}
+		processTimeout = timeout
+	}
+
 	return &Provider{
 		name:    name,
 		creds:   data.Credentials,
 		configs: data.Configs,
+
+		stsEndpoint: data.StsEndpoint,
+		stsRegion:   data.StsRegion,
+		ssoEndpoint: data.SsoEndpoint,
+		ssoCacheDir: data.SsoCacheDir,
+
+		processTimeout: processTimeout,
+
+		sessions: map[string]aws.Credentials{},
 	}, nil
 }
 
@@ -37,6 +63,17 @@ type Provider struct {
 
 	creds   []string
 	configs []string
+
+	stsEndpoint string
+	stsRegion   string
+	ssoEndpoint string
+	ssoCacheDir string
+
+	processTimeout time.Duration
+
+	mu       sync.Mutex
+	prompter types.Prompter
+	sessions map[string]aws.Credentials
 }
 
 // Name returns the provider name.
@@ -47,14 +84,20 @@ func (p *Provider) Name() string {
 // Get will retrieve profile name from provider p. If name is $env the credentials will be
 // retrieved from current environmental variables.
 func (p *Provider) Get(name string) (types.Profile, error) {
+	return p.GetContext(context.Background(), name)
+}
+
+// GetContext will retrieve profile name like Get. ctx is used for requests to aws and
+// when asking for mfa codes.
+func (p *Provider) GetContext(ctx context.Context, name string) (types.Profile, error) {
 	creds, region := aws.Credentials{}, ""
 	var err error
 
 	switch name {
 	case "$default":
-		creds, region, err = p.credsFromDefaultChain()
+		creds, region, err = p.credsFromDefaultChain(ctx)
 	default:
-		creds, region, err = p.credsFromFiles(name)
+		creds, region, err = p.credsFromFiles(ctx, name)
 	}
 	if err != nil {
 		return nil, fmt.Errorf("aws: couldn't get credentials for %q from %q. %w", name, p.Name(), err)
@@ -65,33 +108,76 @@ func (p *Provider) Get(name string) (types.Profile, error) {
 		SecretKey    string `json:"secretKey"`
 		SessionToken string `json:"sessionToken,omitempty"`
 		Region       string `json:"region,omitempty"`
+		Expiration   string `json:"expiration,omitempty"`
 	}{
 		AccessKey:    creds.AccessKeyID,
 		SecretKey:    creds.SecretAccessKey,
 		SessionToken: creds.SessionToken,
 		Region:       region,
 	}
+	metadata := types.Metadata{Source: creds.Source, Kind: types.KindStatic}
+	if creds.SessionToken != "" {
+		metadata.Kind = types.KindSession
+	}
+	if creds.CanExpire {
+		metadata.Expires = creds.Expires
+		raw.Expiration = creds.Expires.UTC().Format(time.RFC3339)
+	}
 
 	payload, _ := json.Marshal(raw)
 
-	return &Profile{name: name, payload: payload}, nil
+	return &Profile{name: name, payload: payload, metadata: metadata}, nil
+}
+
+// Files returns the credentials and config files that profiles are read from
+// and the sso cache directory.
+func (p *Provider) Files() []string {
+	creds, configs := p.creds, p.configs
+	if creds == nil {
+		creds = config.DefaultSharedCredentialsFiles
+	}
+	if configs == nil {
+		configs = config.DefaultSharedConfigFiles
+	}
+
+	return append(append(append([]string{}, creds...), configs...), p.ssoCacheDir)
 }
 
-// credsFromFiles will return credentials and region for name from files.
-func (p *Provider) credsFromFiles(name string) (aws.Credentials, string, error) {
+// credsFromFiles will return credentials and region for name from files. Profiles
+// using role_arn and source_profile will be resolved by assuming the role(s).
+// Profiles with static credentials and mfa_serial will get a session token.
+func (p *Provider) credsFromFiles(ctx context.Context, name string) (aws.Credentials, string, error) {
 	opts := func(opts *config.LoadSharedConfigOptions) {
 		opts.CredentialsFiles = p.creds
 		opts.ConfigFiles = p.configs
 		opts.Logger = nil
 	}
 
-	ctx := context.Background()
 	shared, err := config.LoadSharedConfigProfile(ctx, name, opts)
 	if err != nil {
 		return aws.Credentials{}, "", err
 	}
 
-	return shared.Credentials, shared.Region, nil
+	if shared.Source == nil && shared.MFASerial != "" && shared.Credentials.HasKeys() {
+		if creds, ok := p.session(name); ok {
+			return creds, shared.Region, nil
+		}
+
+		creds, err := p.sessionToken(ctx, &shared, shared.Credentials, shared.Region)
+		if err != nil {
+			return aws.Credentials{}, "", err
+		}
+		p.setSession(name, creds)
+
+		return creds, shared.Region, nil
+	}
+
+	creds, err := p.resolve(ctx, &shared, shared.Region)
+	if err != nil {
+		return aws.Credentials{}, "", err
+	}
+
+	return creds, shared.Region, nil
 }
 
 // credsFromDefaultChain will return credentials and region using the default aws credentials chain.
@@ -101,8 +187,7 @@ func (p *Provider) credsFromFiles(name string) (aws.Credentials, string, error)
 // 2. Credentials / Config i .aws config. (default or set by AWS_PROFILE/AWS_DEFAULT_PROFILE).
 // 3. ECS Task Definition IAM Role.
 // 4. EC2 IAM Role.
-func (p *Provider) credsFromDefaultChain() (aws.Credentials, string, error) {
-	ctx := context.Background()
+func (p *Provider) credsFromDefaultChain(ctx context.Context) (aws.Credentials, string, error) {
 	def, err := config.LoadDefaultConfig(ctx)
 	if err != nil {
 		return aws.Credentials{}, "", err
@@ -118,8 +203,9 @@ func (p *Provider) credsFromDefaultChain() (aws.Credentials, string, error) {
 // Profile satisfies the types.Profile interface and can be used
 // as a profile by the providers package.
 type Profile struct {
-	name    string
-	payload []byte
+	name     string
+	payload  []byte
+	metadata types.Metadata
 }
 
 // Name returns the profile name.
@@ -131,3 +217,8 @@ func (p *Profile) Name() string {
 func (p *Profile) Payload() []byte {
 	return p.payload
 }
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -6,6 +6,8 @@ import (
 	"os"
 	"testing"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -23,25 +25,34 @@ var tests = []struct {
 			"credentials": []string{"./testdata/credentials"},
 		},
 		result: &Provider{
-			name:  "aws1",
-			creds: []string{"./testdata/credentials"},
+			name:        "aws1",
+			creds:       []string{"./testdata/credentials"},
+			stsRegion:   defaultStsRegion,
+			ssoCacheDir: defaultSsoCacheDir(),
+
+			processTimeout: defaultProcessTimeout,
+			sessions:       map[string]aws.Credentials{},
 		},
 		profiles: map[string]*Profile{
 			"default": {
-				name:    "default",
-				payload: []byte(`{"accessKey":"key","secretKey":"secret"}`),
+				name:     "default",
+				payload:  []byte(`{"accessKey":"key","secretKey":"secret"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindStatic},
 			},
 			"default-with-region": {
-				name:    "default-with-region",
-				payload: []byte(`{"accessKey":"key","secretKey":"secret","region":"eu-north-1"}`),
+				name:     "default-with-region",
+				payload:  []byte(`{"accessKey":"key","secretKey":"secret","region":"eu-north-1"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindStatic},
 			},
 			"dev-service": {
-				name:    "dev-service",
-				payload: []byte(`{"accessKey":"dev-key","secretKey":"dev-secret","sessionToken":"dev-token"}`),
+				name:     "dev-service",
+				payload:  []byte(`{"accessKey":"dev-key","secretKey":"dev-secret","sessionToken":"dev-token"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindSession},
 			},
 			"service-prod": {
-				name:    "service-prod",
-				payload: []byte(`{"accessKey":"prod-key","secretKey":"prod-secret","sessionToken":"prod-token"}`),
+				name:     "service-prod",
+				payload:  []byte(`{"accessKey":"prod-key","secretKey":"prod-secret","sessionToken":"prod-token"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindSession},
 			},
 		},
 		profilesError: []string{"notexist"},
@@ -54,26 +65,35 @@ var tests = []struct {
 			"configs":     []interface{}{"./testdata/configs"},
 		},
 		result: &Provider{
-			name:    "aws2",
-			creds:   []string{"./testdata/credentials"},
-			configs: []string{"./testdata/configs"},
+			name:        "aws2",
+			creds:       []string{"./testdata/credentials"},
+			configs:     []string{"./testdata/configs"},
+			stsRegion:   defaultStsRegion,
+			ssoCacheDir: defaultSsoCacheDir(),
+
+			processTimeout: defaultProcessTimeout,
+			sessions:       map[string]aws.Credentials{},
 		},
 		profiles: map[string]*Profile{
 			"default": {
-				name:    "default",
-				payload: []byte(`{"accessKey":"key","secretKey":"secret"}`),
+				name:     "default",
+				payload:  []byte(`{"accessKey":"key","secretKey":"secret"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindStatic},
 			},
 			"default-with-region": {
-				name:    "default-with-region",
-				payload: []byte(`{"accessKey":"key","secretKey":"secret","region":"eu-north-1"}`),
+				name:     "default-with-region",
+				payload:  []byte(`{"accessKey":"key","secretKey":"secret","region":"eu-north-1"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindStatic},
 			},
 			"dev-service": {
-				name:    "dev-service",
-				payload: []byte(`{"accessKey":"dev-key","secretKey":"dev-secret","sessionToken":"dev-token"}`),
+				name:     "dev-service",
+				payload:  []byte(`{"accessKey":"dev-key","secretKey":"dev-secret","sessionToken":"dev-token"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindSession},
 			},
 			"service-prod": {
-				name:    "service-prod",
-				payload: []byte(`{"accessKey":"prod-key","secretKey":"prod-secret","sessionToken":"prod-token","region":"eu-north-1"}`),
+				name:     "service-prod",
+				payload:  []byte(`{"accessKey":"prod-key","secretKey":"prod-secret","sessionToken":"prod-token","region":"eu-north-1"}`),
+				metadata: types.Metadata{Source: "SharedConfigCredentials: ./testdata/credentials", Kind: types.KindSession},
 			},
 		},
 		profilesError: []string{"notexist"},
@@ -93,6 +113,13 @@ var tests = []struct {
 		},
 		err: true,
 	},
+	{
+		name: "aws",
+		config: map[string]interface{}{
+			"credential-process-timeout": "a minute",
+		},
+		err: true,
+	},
 }
 
 func TestLoad(t *testing.T) {
@@ -0,0 +1,90 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// sessionWindow is how long before expiry a cached mfa session will no longer be used.
+const sessionWindow = time.Minute
+
+// SetPrompter sets the prompter used to ask for mfa tokens.
+func (p *Provider) SetPrompter(prompter types.Prompter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompter = prompter
+}
+
+// mfaToken will ask the user for the mfa token for the mfa device of profile shared.
+func (p *Provider) mfaToken(ctx context.Context, shared *config.SharedConfig) (string, error) {
+	p.mu.Lock()
+	prompter := p.prompter
+	p.mu.Unlock()
+
+	if prompter == nil {
+		return "", fmt.Errorf("profile %q requires mfa but there is no way to ask for it", shared.Profile)
+	}
+
+	token, err := prompter.Prompt(ctx, "enter mfa code for %q (%s) %s: ", shared.Profile, p.name, shared.MFASerial)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get mfa code for profile %q. %w", shared.Profile, err)
+	}
+	if token == "" {
+		return "", fmt.Errorf("no mfa code entered for profile %q", shared.Profile)
+	}
+
+	return token, nil
+}
+
+// sessionToken will get a session token for profile shared using the mfa device of
+// the profile and the static credentials creds.
+func (p *Provider) sessionToken(ctx context.Context, shared *config.SharedConfig, creds aws.Credentials, region string) (aws.Credentials, error) {
+	token, err := p.mfaToken(ctx, shared)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	out, err := p.stsClient(creds, region).GetSessionToken(ctx, &sts.GetSessionTokenInput{
+		SerialNumber: aws.String(shared.MFASerial),
+		TokenCode:    aws.String(token),
+	})
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("couldn't get session token for profile %q. %w", shared.Profile, err)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(out.Credentials.SessionToken),
+		Source:          fmt.Sprintf("GetSessionToken: %s", shared.MFASerial),
+		CanExpire:       true,
+		Expires:         aws.ToTime(out.Credentials.Expiration),
+	}, nil
+}
+
+// session returns the cached mfa session for profile name if it hasn't expired.
+func (p *Provider) session(name string) (aws.Credentials, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	creds, ok := p.sessions[name]
+	if !ok || creds.Expires.Before(time.Now().Add(sessionWindow)) {
+		delete(p.sessions, name)
+		return aws.Credentials{}, false
+	}
+
+	return creds, true
+}
+
+// setSession will cache the mfa session creds for profile name until it expires.
+func (p *Provider) setSession(name string, creds aws.Credentials) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sessions[name] = creds
+}
@@ -0,0 +1,67 @@
+package aws
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// defaultProcessTimeout is how long credential_process may run if not configured.
+const defaultProcessTimeout = time.Minute
+
+// processOutput is the output of a credential_process.
+type processOutput struct {
+	Version         int    `json:"Version"`
+	AccessKeyID     string `json:"AccessKeyId"`
+	SecretAccessKey string `json:"SecretAccessKey"`
+	SessionToken    string `json:"SessionToken"`
+	Expiration      string `json:"Expiration"`
+}
+
+// processCredentials will run the credential_process of profile shared and return
+// the credentials it outputs.
+func (p *Provider) processCredentials(shared *config.SharedConfig) (aws.Credentials, error) {
+	cmd := &process.Command{
+		Args:    process.Shell(shared.CredentialProcess),
+		Timeout: p.processTimeout,
+	}
+
+	raw, err := cmd.Run()
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("couldn't run credential_process for profile %q. %w", shared.Profile, err)
+	}
+
+	out := &processOutput{}
+	if err := json.Unmarshal(raw, out); err != nil {
+		return aws.Credentials{}, fmt.Errorf("couldn't json unmarshal credential_process output for profile %q. %w", shared.Profile, err)
+	}
+
+	switch {
+	case out.Version != 1:
+		return aws.Credentials{}, fmt.Errorf("credential_process for profile %q returned unsupported version %d", shared.Profile, out.Version)
+	case out.AccessKeyID == "" || out.SecretAccessKey == "":
+		return aws.Credentials{}, fmt.Errorf("credential_process for profile %q returned no AccessKeyId or SecretAccessKey", shared.Profile)
+	}
+
+	creds := aws.Credentials{
+		AccessKeyID:     out.AccessKeyID,
+		SecretAccessKey: out.SecretAccessKey,
+		SessionToken:    out.SessionToken,
+		Source:          fmt.Sprintf("CredentialProcess: %s", shared.Profile),
+	}
+
+	if out.Expiration != "" {
+		expires, err := time.Parse(time.RFC3339, out.Expiration)
+		if err != nil {
+			return aws.Credentials{}, fmt.Errorf("couldn't parse credential_process Expiration for profile %q. %w", shared.Profile, err)
+		}
+		creds.CanExpire = true
+		creds.Expires = expires
+	}
+
+	return creds, nil
+}
@@ -0,0 +1,35 @@
+package aws
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCredentialProcess(t *testing.T) {
+	provider, err := Create("aws-process", map[string]interface{}{
+		"credentials":                []string{},
+		"configs":                    []string{"./testdata/process-configs"},
+		"credential-process-timeout": "200ms",
+	})
+	assert.NoError(t, err)
+
+	profile, err := provider.Get("process")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"accessKey":"process-key","secretKey":"process-secret"}`, string(profile.Payload()))
+
+	profile, err = provider.Get("process-session")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"accessKey":"process-key","secretKey":"process-secret","sessionToken":"process-token","expiration":"2100-01-01T00:00:00Z"}`, string(profile.Payload()))
+
+	_, err = provider.Get("process-fail")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "key broker is unavailable")
+
+	_, err = provider.Get("process-version")
+	assert.Error(t, err)
+
+	_, err = provider.Get("process-slow")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "timed out after 200ms")
+}
@@ -0,0 +1,104 @@
+package aws
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sso"
+)
+
+// defaultSsoCacheDir returns the directory the aws cli stores sso tokens in.
+func defaultSsoCacheDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".aws", "sso", "cache")
+}
+
+// ssoToken is a cached sso access token created by "aws sso login".
+type ssoToken struct {
+	AccessToken string `json:"accessToken"`
+	ExpiresAt   string `json:"expiresAt"`
+}
+
+// ssoCredentials will return the role credentials for the sso account and role in profile shared.
+// The sso access token is read from the sso cache and must have been created by "aws sso login".
+func (p *Provider) ssoCredentials(ctx context.Context, shared *config.SharedConfig) (aws.Credentials, error) {
+	token, err := p.ssoToken(shared)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	opts := sso.Options{Region: shared.SSORegion}
+	if p.ssoEndpoint != "" {
+		opts.EndpointResolver = sso.EndpointResolverFromURL(p.ssoEndpoint)
+	}
+
+	out, err := sso.New(opts).GetRoleCredentials(ctx, &sso.GetRoleCredentialsInput{
+		AccessToken: aws.String(token),
+		AccountId:   aws.String(shared.SSOAccountID),
+		RoleName:    aws.String(shared.SSORoleName),
+	})
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("couldn't get sso role credentials for profile %q. %w", shared.Profile, err)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(out.RoleCredentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(out.RoleCredentials.SecretAccessKey),
+		SessionToken:    aws.ToString(out.RoleCredentials.SessionToken),
+		Source:          fmt.Sprintf("SSO: %s/%s", shared.SSOAccountID, shared.SSORoleName),
+		CanExpire:       true,
+		Expires:         time.Unix(0, out.RoleCredentials.Expiration*int64(time.Millisecond)),
+	}, nil
+}
+
+// ssoToken will return the cached sso access token for the sso start url of profile shared.
+// Returns an error telling the user to login if the token is missing or has expired.
+func (p *Provider) ssoToken(shared *config.SharedConfig) (string, error) {
+	login := fmt.Sprintf("run \"aws sso login --profile %s\" to login", shared.Profile)
+
+	hash := sha1.Sum([]byte(shared.SSOStartURL))
+	fn := filepath.Join(p.ssoCacheDir, hex.EncodeToString(hash[:])+".json")
+
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("no sso session for profile %q. %s", shared.Profile, login)
+		}
+		return "", fmt.Errorf("couldn't read sso cache file %q. %w", fn, err)
+	}
+
+	token := &ssoToken{}
+	if err := json.Unmarshal(raw, token); err != nil {
+		return "", fmt.Errorf("couldn't json unmarshal sso cache file %q. %w", fn, err)
+	}
+
+	expires, err := parseSsoExpiry(token.ExpiresAt)
+	if err != nil {
+		return "", fmt.Errorf("couldn't parse expiry in sso cache file %q. %w", fn, err)
+	}
+	if token.AccessToken == "" || !time.Now().Before(expires) {
+		return "", fmt.Errorf("sso session for profile %q has expired. %s", shared.Profile, login)
+	}
+
+	return token.AccessToken, nil
+}
+
+// parseSsoExpiry parses the expiry of a cached sso token. Older versions of the
+// aws cli wrote the time zone as "UTC" instead of "Z".
+func parseSsoExpiry(expiresAt string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05UTC"} {
+		if expires, err := time.Parse(layout, expiresAt); err == nil {
+			return expires, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", expiresAt)
+}
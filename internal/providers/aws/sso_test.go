@@ -0,0 +1,50 @@
+package aws
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ssoStub returns a server that behaves like the sso portal and only accepts valid-token.
+func ssoStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/federation/credentials" || r.Header.Get("x-amz-sso_bearer_token") != "valid-token" {
+			w.Header().Set("X-Amzn-ErrorType", "UnauthorizedException")
+			w.WriteHeader(401)
+			fmt.Fprint(w, `{"message":"Session token not found or invalid"}`)
+			return
+		}
+
+		fmt.Fprintf(w, `{"roleCredentials":{"accessKeyId":"%s-%s-key","secretAccessKey":"sso-secret","sessionToken":"sso-token","expiration":4102444800000}}`,
+			query.Get("account_id"), query.Get("role_name"))
+	}))
+}
+
+func TestSso(t *testing.T) {
+	stub := ssoStub()
+	defer stub.Close()
+
+	provider, err := Create("aws-sso", map[string]interface{}{
+		"credentials":   []string{},
+		"configs":       []string{"./testdata/sso-configs"},
+		"sso-endpoint":  stub.URL,
+		"sso-cache-dir": "./testdata/sso",
+	})
+	assert.NoError(t, err)
+
+	profile, err := provider.Get("sso")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"accessKey":"123456789012-Developer-key","secretKey":"sso-secret","sessionToken":"sso-token","region":"eu-north-1","expiration":"2100-01-01T00:00:00Z"}`, string(profile.Payload()))
+	assert.Equal(t, "SSO: 123456789012/Developer", profile.Metadata().Source)
+
+	_, err = provider.Get("sso-expired")
+	assert.EqualError(t, err, `aws: couldn't get credentials for "sso-expired" from "aws-sso". sso session for profile "sso-expired" has expired. run "aws sso login --profile sso-expired" to login`)
+
+	_, err = provider.Get("sso-missing")
+	assert.Error(t, err)
+}
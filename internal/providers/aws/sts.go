@@ -0,0 +1,120 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// defaultStsRegion is the region used for sts if the profile doesn't specify one.
+const defaultStsRegion = "us-east-1"
+
+// resolve will return the credentials for profile shared. If shared is a role with a source
+// profile the source profile will be resolved first and then used to assume the role.
+// Chained roles are resolved recursively. Roles requiring mfa are cached until they expire.
+// Profiles using sso will get role credentials using the cached sso token and profiles
+// with credential_process will get the credentials by running the process.
+func (p *Provider) resolve(ctx context.Context, shared *config.SharedConfig, region string) (aws.Credentials, error) {
+	switch {
+	case shared.Source != nil:
+		if shared.MFASerial != "" {
+			if creds, ok := p.session(shared.Profile); ok {
+				return creds, nil
+			}
+		}
+
+		source, err := p.resolve(ctx, shared.Source, region)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+
+		creds, err := p.assumeRole(ctx, shared, source, region)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+
+		if shared.MFASerial != "" {
+			p.setSession(shared.Profile, creds)
+		}
+		return creds, nil
+
+	case shared.SSOStartURL != "":
+		return p.ssoCredentials(ctx, shared)
+
+	case shared.CredentialProcess != "":
+		return p.processCredentials(shared)
+
+	case shared.Credentials.HasKeys():
+		return shared.Credentials, nil
+
+	case shared.RoleARN != "":
+		return aws.Credentials{}, fmt.Errorf("role %q in profile %q must have a %s", shared.RoleARN, shared.Profile, "source_profile")
+	}
+
+	return shared.Credentials, nil
+}
+
+// assumeRole will assume the role in shared using source credentials and return
+// the temporary credentials of the role session.
+func (p *Provider) assumeRole(ctx context.Context, shared *config.SharedConfig, source aws.Credentials, region string) (aws.Credentials, error) {
+	input := &sts.AssumeRoleInput{
+		RoleArn:         aws.String(shared.RoleARN),
+		RoleSessionName: aws.String(shared.RoleSessionName),
+	}
+	if shared.RoleSessionName == "" {
+		input.RoleSessionName = aws.String(fmt.Sprintf("pm-creds-%d", time.Now().Unix()))
+	}
+	if shared.ExternalID != "" {
+		input.ExternalId = aws.String(shared.ExternalID)
+	}
+	if shared.RoleDurationSeconds != nil {
+		input.DurationSeconds = aws.Int32(int32(shared.RoleDurationSeconds.Seconds()))
+	}
+	if shared.MFASerial != "" {
+		token, err := p.mfaToken(ctx, shared)
+		if err != nil {
+			return aws.Credentials{}, err
+		}
+		input.SerialNumber = aws.String(shared.MFASerial)
+		input.TokenCode = aws.String(token)
+	}
+
+	out, err := p.stsClient(source, region).AssumeRole(ctx, input)
+	if err != nil {
+		return aws.Credentials{}, fmt.Errorf("couldn't assume role %q for profile %q. %w", shared.RoleARN, shared.Profile, err)
+	}
+
+	return aws.Credentials{
+		AccessKeyID:     aws.ToString(out.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(out.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(out.Credentials.SessionToken),
+		Source:          fmt.Sprintf("AssumeRole: %s", shared.RoleARN),
+		CanExpire:       true,
+		Expires:         aws.ToTime(out.Credentials.Expiration),
+	}, nil
+}
+
+// stsClient returns a sts client for region that signs requests with creds.
+// If the provider has a sts endpoint configured it will be used instead of the
+// default aws endpoint.
+func (p *Provider) stsClient(creds aws.Credentials, region string) *sts.Client {
+	if region == "" {
+		region = p.stsRegion
+	}
+
+	opts := sts.Options{
+		Region: region,
+		Credentials: aws.CredentialsProviderFunc(func(context.Context) (aws.Credentials, error) {
+			return creds, nil
+		}),
+	}
+	if p.stsEndpoint != "" {
+		opts.EndpointResolver = sts.EndpointResolverFromURL(p.stsEndpoint)
+	}
+
+	return sts.New(opts)
+}
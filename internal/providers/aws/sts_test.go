@@ -0,0 +1,148 @@
+package aws
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	stsExpiration = "2100-01-01T00:00:00Z"
+	stsMfaSerial  = "arn:aws:iam::123456789012:mfa/user"
+	stsMfaToken   = "123456"
+)
+
+// stsRoles maps the roles the sts stub can assume to the access key that
+// must be used to sign the request and the access key that will be returned.
+var stsRoles = map[string][2]string{
+	"arn:aws:iam::123456789012:role/role":    {"base-key", "role-key"},
+	"arn:aws:iam::123456789012:role/chained": {"role-key", "chained-key"},
+	"arn:aws:iam::123456789012:role/mfa":     {"base-key", "mfa-key"},
+}
+
+// prompterMock answers all prompts with answer and counts the prompts.
+type prompterMock struct {
+	answer  string
+	prompts int
+}
+
+func (pm *prompterMock) Prompt(ctx context.Context, format string, a ...interface{}) (string, error) {
+	pm.prompts++
+	return pm.answer, nil
+}
+
+func (pm *prompterMock) PromptSecret(ctx context.Context, format string, a ...interface{}) (string, error) {
+	return pm.Prompt(ctx, format, a...)
+}
+
+// stsStub returns a server that behaves like sts for the roles in stsRoles.
+func stsStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		action, keys, ok := r.Form.Get("Action"), [2]string{}, false
+		switch action {
+		case "AssumeRole":
+			keys, ok = stsRoles[r.Form.Get("RoleArn")]
+		case "GetSessionToken":
+			keys, ok = [2]string{"base-key", "session-key"}, true
+		}
+
+		mfa := r.Form.Get("SerialNumber") == stsMfaSerial && r.Form.Get("TokenCode") == stsMfaToken
+		if strings.Contains(r.Form.Get("RoleArn"), "mfa") || action == "GetSessionToken" {
+			ok = ok && mfa
+		}
+
+		if !ok || !strings.Contains(r.Header.Get("Authorization"), "Credential="+keys[0]+"/") {
+			w.WriteHeader(403)
+			fmt.Fprint(w, `<ErrorResponse><Error><Type>Sender</Type><Code>AccessDenied</Code><Message>denied</Message></Error><RequestId>id</RequestId></ErrorResponse>`)
+			return
+		}
+
+		fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <%[1]sResult>
+    <Credentials>
+      <AccessKeyId>%[2]s</AccessKeyId>
+      <SecretAccessKey>%[3]s-secret</SecretAccessKey>
+      <SessionToken>%[2]s-token</SessionToken>
+      <Expiration>%[4]s</Expiration>
+    </Credentials>
+  </%[1]sResult>
+  <ResponseMetadata><RequestId>id</RequestId></ResponseMetadata>
+</%[1]sResponse>`, action, keys[1], r.Form.Get("RoleSessionName"), stsExpiration)
+	}))
+}
+
+func TestAssumeRole(t *testing.T) {
+	stub := stsStub()
+	defer stub.Close()
+
+	provider, err := Create("aws-roles", map[string]interface{}{
+		"credentials":  []string{},
+		"configs":      []string{"./testdata/roles"},
+		"sts-endpoint": stub.URL,
+	})
+	assert.NoError(t, err)
+
+	profile, err := provider.Get("role")
+	assert.NoError(t, err)
+	assert.Equal(t, "role", profile.Name())
+	assert.Regexp(t, `^{"accessKey":"role-key","secretKey":"pm-creds-\d+-secret","sessionToken":"role-key-token","region":"eu-north-1","expiration":"2100-01-01T00:00:00Z"}$`, string(profile.Payload()))
+
+	profile, err = provider.Get("chained")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"accessKey":"chained-key","secretKey":"chained-session-secret","sessionToken":"chained-key-token","expiration":"2100-01-01T00:00:00Z"}`, string(profile.Payload()))
+	assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+	assert.Equal(t, "AssumeRole: arn:aws:iam::123456789012:role/chained", profile.Metadata().Source)
+	assert.Equal(t, time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC), profile.Metadata().Expires.UTC())
+
+	_, err = provider.Get("denied")
+	assert.Error(t, err)
+}
+
+func TestMfa(t *testing.T) {
+	stub := stsStub()
+	defer stub.Close()
+
+	provider, err := Create("aws-mfa", map[string]interface{}{
+		"credentials":  []string{},
+		"configs":      []string{"./testdata/roles"},
+		"sts-endpoint": stub.URL,
+	})
+	assert.NoError(t, err)
+
+	_, err = provider.Get("mfa-role")
+	assert.Error(t, err)
+
+	prompter := &prompterMock{answer: stsMfaToken}
+	provider.SetPrompter(prompter)
+
+	for i := 0; i < 2; i++ {
+		profile, err := provider.Get("mfa-role")
+		assert.NoError(t, err)
+		assert.Regexp(t, `^{"accessKey":"mfa-key",`, string(profile.Payload()))
+
+		profile, err = provider.Get("mfa-user")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"accessKey":"session-key","secretKey":"-secret","sessionToken":"session-key-token","expiration":"2100-01-01T00:00:00Z"}`, string(profile.Payload()))
+	}
+	assert.Equal(t, 2, prompter.prompts)
+
+	provider, err = Create("aws-mfa-wrong", map[string]interface{}{
+		"credentials":  []string{},
+		"configs":      []string{"./testdata/roles"},
+		"sts-endpoint": stub.URL,
+	})
+	assert.NoError(t, err)
+
+	provider.SetPrompter(&prompterMock{answer: "654321"})
+	_, err = provider.Get("mfa-user")
+	assert.Error(t, err)
+}
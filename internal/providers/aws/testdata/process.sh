@@ -0,0 +1,19 @@
+#!/bin/sh
+case "$1" in
+ok)
+	echo '{"Version": 1, "AccessKeyId": "process-key", "SecretAccessKey": "process-secret"}'
+	;;
+session)
+	echo '{"Version": 1, "AccessKeyId": "process-key", "SecretAccessKey": "process-secret", "SessionToken": "process-token", "Expiration": "2100-01-01T00:00:00Z"}'
+	;;
+version)
+	echo '{"Version": 2, "AccessKeyId": "process-key", "SecretAccessKey": "process-secret"}'
+	;;
+slow)
+	sleep 5
+	;;
+*)
+	echo "key broker is unavailable" >&2
+	exit 1
+	;;
+esac
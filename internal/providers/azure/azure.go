@@ -0,0 +1,243 @@
+// Package azure is a provider that can be used by the providers package to
+// retrieve bearer tokens for azure service principals using the oauth2
+// client credentials flow.
+package azure
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/oauth"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Defaults used if not configured.
+const (
+	defaultAuthority = "https://login.microsoftonline.com"
+	defaultScope     = "https://management.azure.com/.default"
+	defaultTimeout   = 30 * time.Second
+)
+
+// profile is the configuration of a single service principal.
+type profile struct {
+	TenantID     string `mapstructure:"tenant-id"`
+	ClientID     string `mapstructure:"client-id"`
+	ClientSecret string `mapstructure:"client-secret"`
+	Scope        string `mapstructure:"scope"`
+}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Authority   string              `mapstructure:"authority"`
+		Credentials string              `mapstructure:"credentials"`
+		Scope       string              `mapstructure:"scope"`
+		Timeout     string              `mapstructure:"timeout"`
+		Profiles    map[string]*profile `mapstructure:"profiles"`
+	}{
+		Authority:   defaultAuthority,
+		Credentials: defaultCredentials(),
+		Scope:       defaultScope,
+	}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("azure: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	if _, err := url.ParseRequestURI(data.Authority); err != nil {
+		return nil, fmt.Errorf("azure: couldn't parse %s for %q. %w", "authority", name, err)
+	}
+
+	timeout := defaultTimeout
+	if data.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(data.Timeout); err != nil {
+			return nil, fmt.Errorf("azure: couldn't parse %s for %q. %w", "timeout", name, err)
+		}
+	}
+
+	for profileName, cfg := range data.Profiles {
+		if cfg.TenantID == "" || cfg.ClientID == "" {
+			return nil, fmt.Errorf("azure: no tenant-id or client-id set in profile %q for %q", profileName, name)
+		}
+	}
+
+	return &Provider{
+		name:        name,
+		authority:   strings.TrimSuffix(data.Authority, "/"),
+		credentials: data.Credentials,
+		scope:       data.Scope,
+		profiles:    data.Profiles,
+		client:      &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// defaultCredentials returns the file where the azure cli stores service principal secrets.
+func defaultCredentials() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".azure", "service_principal_entries.json")
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	authority   string
+	credentials string
+	scope       string
+	profiles    map[string]*profile
+	client      *http.Client
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will request a bearer token for the service principal of profile name. Profiles not
+// configured are looked up by client id in the credentials file. If the client secret isn't
+// configured it's read from the credentials file.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	cfg, ok := p.profiles[name]
+	if !ok {
+		cfg = &profile{ClientID: name}
+	}
+
+	sp := *cfg
+	if sp.ClientSecret == "" || sp.TenantID == "" {
+		entry, err := p.lookup(sp.ClientID)
+		if err != nil {
+			return nil, fmt.Errorf("azure: couldn't get secret for %q from %q. %w", name, p.Name(), err)
+		}
+		if sp.TenantID == "" {
+			sp.TenantID = entry.TenantID
+		}
+		if sp.ClientSecret == "" {
+			sp.ClientSecret = entry.ClientSecret
+		}
+	}
+	if sp.Scope == "" {
+		sp.Scope = p.scope
+	}
+
+	tokenURL := fmt.Sprintf("%s/%s/oauth2/v2.0/token", p.authority, url.PathEscape(sp.TenantID))
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {sp.ClientID},
+		"client_secret": {sp.ClientSecret},
+		"scope":         {sp.Scope},
+	}
+
+	token, err := oauth.Request(p.client, tokenURL, form, nil)
+	if err != nil {
+		return nil, fmt.Errorf("azure: couldn't get token for %q from %q. %w", name, p.Name(), err)
+	}
+
+	fields := token.Payload()
+	fields["tenantId"] = sp.TenantID
+	fields["clientId"] = sp.ClientID
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("azure: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Expires: token.Expires,
+			Source:  fmt.Sprintf("azure: %s", sp.TenantID),
+			Kind:    types.KindSession,
+		},
+	}, nil
+}
+
+// Files returns the credentials file.
+func (p *Provider) Files() []string {
+	if p.credentials == "" {
+		return nil
+	}
+	return []string{p.credentials}
+}
+
+// entry is a service principal in the credentials file.
+type entry struct {
+	ClientID     string
+	TenantID     string
+	ClientSecret string
+}
+
+// lookup will return the service principal with clientID from the credentials file. Both the current
+// format of the azure cli and the older format using servicePrincipalId are supported.
+func (p *Provider) lookup(clientID string) (*entry, error) {
+	if p.credentials == "" {
+		return nil, fmt.Errorf("no credentials file set")
+	}
+
+	raw, err := os.ReadFile(p.credentials)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("no service principal %q. credentials file %q doesn't exist", clientID, p.credentials)
+		}
+		return nil, fmt.Errorf("couldn't read credentials file %q. %w", p.credentials, err)
+	}
+
+	entries := []struct {
+		ClientID     string `json:"client_id"`
+		Tenant       string `json:"tenant"`
+		ClientSecret string `json:"client_secret"`
+
+		ServicePrincipalID     string `json:"servicePrincipalId"`
+		ServicePrincipalTenant string `json:"servicePrincipalTenant"`
+		AccessToken            string `json:"accessToken"`
+	}{}
+	if err := json.Unmarshal(raw, &entries); err != nil {
+		return nil, fmt.Errorf("couldn't json unmarshal credentials file %q. %w", p.credentials, err)
+	}
+
+	for _, e := range entries {
+		switch {
+		case e.ClientID != "" && e.ClientID == clientID:
+			return &entry{ClientID: e.ClientID, TenantID: e.Tenant, ClientSecret: e.ClientSecret}, nil
+		case e.ServicePrincipalID != "" && e.ServicePrincipalID == clientID:
+			return &entry{ClientID: e.ServicePrincipalID, TenantID: e.ServicePrincipalTenant, ClientSecret: e.AccessToken}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no service principal %q in credentials file %q", clientID, p.credentials)
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
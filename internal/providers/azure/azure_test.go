@@ -0,0 +1,108 @@
+package azure
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// secrets are the client secrets accepted by the authority stub.
+var secrets = map[string]string{
+	"tenant/11111111-1111-1111-1111-111111111111":     "file-secret",
+	"old-tenant/22222222-2222-2222-2222-222222222222": "old-secret",
+	"tenant/configured":                               "configured-secret",
+}
+
+func authorityStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")[0]
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" || secrets[tenant+"/"+r.PostForm.Get("client_id")] != r.PostForm.Get("client_secret") {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client","error_description":"AADSTS7000215: Invalid client secret provided."}`)
+			return
+		}
+		fmt.Fprintf(w, `{"token_type":"Bearer","expires_in":3599,"access_token":"token-%s-%s"}`, tenant, r.PostForm.Get("scope"))
+	}))
+}
+
+func TestProvider(t *testing.T) {
+	server := authorityStub()
+	defer server.Close()
+
+	provider, err := Create("azure", map[string]interface{}{
+		"authority":   server.URL,
+		"credentials": "./testdata/service_principal_entries.json",
+		"profiles": map[string]interface{}{
+			"configured": map[string]interface{}{
+				"tenant-id":     "tenant",
+				"client-id":     "configured",
+				"client-secret": "configured-secret",
+				"scope":         "https://graph.microsoft.com/.default",
+			},
+			"from-file": map[string]interface{}{
+				"tenant-id": "tenant",
+				"client-id": "11111111-1111-1111-1111-111111111111",
+			},
+			"wrong-secret": map[string]interface{}{
+				"tenant-id":     "tenant",
+				"client-id":     "configured",
+				"client-secret": "wrong",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "azure", provider.Name())
+	assert.Equal(t, []string{"./testdata/service_principal_entries.json"}, provider.Files())
+
+	for name, expected := range map[string]map[string]string{
+		"configured": {"tenantId": "tenant", "clientId": "configured", "accessToken": "token-tenant-https://graph.microsoft.com/.default"},
+		"from-file":  {"tenantId": "tenant", "clientId": "11111111-1111-1111-1111-111111111111", "accessToken": "token-tenant-" + defaultScope},
+		"22222222-2222-2222-2222-222222222222": {
+			"tenantId": "old-tenant", "clientId": "22222222-2222-2222-2222-222222222222", "accessToken": "token-old-tenant-" + defaultScope,
+		},
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), profile.Metadata().Expires, time.Minute)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		for key, value := range expected {
+			assert.Equal(t, value, fields[key])
+		}
+		assert.Equal(t, "Bearer", fields["tokenType"])
+		assert.Equal(t, profile.Metadata().Expires.UTC().Format(time.RFC3339), fields["expiration"])
+	}
+
+	_, err = provider.Get("wrong-secret")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid_client")
+
+	_, err = provider.Get("notexist")
+	assert.Error(t, err)
+}
+
+func TestCreateErrors(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"authority": "not a url"},
+		{"timeout": "forever"},
+		{"profiles": map[string]interface{}{"x": map[string]interface{}{"client-id": "client"}}},
+		{"scope": []string{"scope"}},
+	} {
+		_, err := Create("azure", config)
+		assert.Error(t, err, config)
+	}
+}
@@ -0,0 +1,238 @@
+package providers
+
+import (
+	"context"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// expiryWindow is how long before credentials expires they will no longer be
+// returned from the cache.
+const expiryWindow = time.Minute
+
+// cache wraps a provider and caches the profiles it returns. Profiles with credentials that
+// expires are cached until shortly before they expire and other profiles are cached for ttl.
+// When 3/4 of the time a profile is cached for has passed it will be refreshed in the
+// background, unless the provider could ask the user to interact and isn't a types.Refresher.
+// All profiles are invalidated if any of the files the provider reads changes.
+// Concurrent fetches of the same profile shares a single call to the provider.
+type cache struct {
+	provider types.Provider
+	ttl      time.Duration
+
+	mu       sync.Mutex
+	entries  map[string]*entry
+	files    map[string]time.Time
+	inflight map[string]*call
+}
+
+// call is a fetch of a profile from the cached provider that is in progress.
+// cancelled is set if the ctx of the caller making the call was done when it returned.
+type call struct {
+	done      chan struct{}
+	profile   types.Profile
+	err       error
+	cancelled bool
+}
+
+// entry is a cached profile.
+type entry struct {
+	profile    types.Profile
+	refresh    time.Time
+	expires    time.Time
+	refreshing bool
+}
+
+// newCache returns provider wrapped in a cache that caches static credentials for ttl.
+func newCache(provider types.Provider, ttl time.Duration) *cache {
+	c := &cache{
+		provider: provider,
+		ttl:      ttl,
+		entries:  map[string]*entry{},
+		inflight: map[string]*call{},
+	}
+	c.files = c.modTimes()
+
+	return c
+}
+
+// Name returns the name of the cached provider.
+func (c *cache) Name() string {
+	return c.provider.Name()
+}
+
+// Get will return profile name from the cache if it's cached and hasn't expired. Otherwise
+// the profile will be retrieved from the cached provider.
+func (c *cache) Get(name string) (types.Profile, error) {
+	return c.GetContext(context.Background(), name)
+}
+
+// GetContext will return profile name like Get. ctx is passed to the cached provider if it's
+// a types.ContextGetter.
+func (c *cache) GetContext(ctx context.Context, name string) (types.Profile, error) {
+	c.mu.Lock()
+	if files := c.modTimes(); !sameModTimes(c.files, files) {
+		c.entries = map[string]*entry{}
+		c.files = files
+	}
+
+	now := time.Now()
+	e, ok := c.entries[name]
+	if ok && now.Before(e.expires) {
+		if now.After(e.refresh) && !e.refreshing && c.refreshable() {
+			e.refreshing = true
+			go c.refresh(name)
+		}
+		c.mu.Unlock()
+		return e.profile, nil
+	}
+	c.mu.Unlock()
+
+	return c.fetch(ctx, name)
+}
+
+// SetPrompter will set prompter on the cached provider if it needs to ask the user for input.
+func (c *cache) SetPrompter(prompter types.Prompter) {
+	if setter, ok := c.provider.(types.PromptSetter); ok {
+		setter.SetPrompter(prompter)
+	}
+}
+
+// SetAuthorizer will set authorizer on the cached provider if it needs the user to authorize in a browser.
+func (c *cache) SetAuthorizer(authorizer types.Authorizer) bool {
+	if setter, ok := c.provider.(types.AuthorizerSetter); ok {
+		return setter.SetAuthorizer(authorizer)
+	}
+	return false
+}
+
+// fetch will get profile name from the cached provider and cache it. If profile name is
+// already being fetched it will wait for and return the result of that fetch instead, or
+// make a new fetch if that one failed because the ctx of its caller was done.
+func (c *cache) fetch(ctx context.Context, name string) (types.Profile, error) {
+	for {
+		c.mu.Lock()
+		cl, ok := c.inflight[name]
+		if !ok {
+			break
+		}
+		c.mu.Unlock()
+
+		select {
+		case <-cl.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		if cl.err != nil && cl.cancelled && ctx.Err() == nil {
+			continue
+		}
+		return cl.profile, cl.err
+	}
+	cl := &call{done: make(chan struct{})}
+	c.inflight[name] = cl
+	c.mu.Unlock()
+
+	if getter, ok := c.provider.(types.ContextGetter); ok {
+		cl.profile, cl.err = getter.GetContext(ctx, name)
+	} else {
+		cl.profile, cl.err = c.provider.Get(name)
+	}
+	cl.cancelled = ctx.Err() != nil
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, name)
+	close(cl.done)
+
+	return c.store(name, cl.profile, cl.err)
+}
+
+// refreshable returns true if profiles can be refreshed in the background. Providers that
+// could ask the user to interact are only refreshed if they can do it without interaction,
+// otherwise the profile is fetched by the next request after it expires.
+func (c *cache) refreshable() bool {
+	if _, ok := c.provider.(types.Refresher); ok {
+		return true
+	}
+	_, prompts := c.provider.(types.PromptSetter)
+	_, authorizes := c.provider.(types.AuthorizerSetter)
+	return !prompts && !authorizes
+}
+
+// refresh will get profile name from the cached provider in the background and cache it.
+// Providers that are a types.Refresher are refreshed without interaction.
+func (c *cache) refresh(name string) {
+	refresher, ok := c.provider.(types.Refresher)
+	if !ok {
+		c.fetch(context.Background(), name)
+		return
+	}
+
+	profile, err := refresher.Refresh(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.store(name, profile, err)
+}
+
+// store will cache profile as name unless err is set. c.mu must be held by the caller.
+func (c *cache) store(name string, profile types.Profile, err error) (types.Profile, error) {
+	if err != nil {
+		if e, ok := c.entries[name]; ok {
+			e.refreshing = false
+		}
+		return nil, err
+	}
+
+	now, expires := time.Now(), time.Now().Add(c.ttl)
+	if metadata := profile.Metadata(); !metadata.Expires.IsZero() {
+		expires = metadata.Expires.Add(-expiryWindow)
+	}
+
+	if expires.After(now) {
+		c.entries[name] = &entry{
+			profile: profile,
+			refresh: now.Add(expires.Sub(now) * 3 / 4),
+			expires: expires,
+		}
+	}
+
+	return profile, nil
+}
+
+// modTimes returns the modification times of the files the cached provider reads
+// credentials from. Files that doesn't exist have zero time.
+func (c *cache) modTimes() map[string]time.Time {
+	reader, ok := c.provider.(types.FileReader)
+	if !ok {
+		return nil
+	}
+
+	modTimes := map[string]time.Time{}
+	for _, fn := range reader.Files() {
+		info, err := os.Stat(fn)
+		if err != nil {
+			modTimes[fn] = time.Time{}
+			continue
+		}
+		modTimes[fn] = info.ModTime()
+	}
+
+	return modTimes
+}
+
+// sameModTimes returns true if a and b contains the same files and modification times.
+func sameModTimes(a map[string]time.Time, b map[string]time.Time) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for fn, modTime := range a {
+		if other, ok := b[fn]; !ok || !other.Equal(modTime) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,254 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// providerMock returns profiles that expires after lifetime and counts
+// how many times each profile has been retrieved. Each get takes delay.
+type providerMock struct {
+	mu       sync.Mutex
+	lifetime time.Duration
+	delay    time.Duration
+	files    []string
+	gets     map[string]int
+}
+
+func (pm *providerMock) Name() string {
+	return "mock"
+}
+
+func (pm *providerMock) Get(name string) (types.Profile, error) {
+	time.Sleep(pm.delay)
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	if name == "error" {
+		return nil, fmt.Errorf("mock error")
+	}
+
+	pm.gets[name]++
+	profile := &profileMock{name: name, payload: []byte(fmt.Sprintf("%d", pm.gets[name]))}
+	if pm.lifetime > 0 {
+		profile.metadata.Expires = time.Now().Add(pm.lifetime)
+	}
+
+	return profile, nil
+}
+
+func (pm *providerMock) Files() []string {
+	return pm.files
+}
+
+func (pm *providerMock) count(name string) int {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	return pm.gets[name]
+}
+
+// refresherMock is a providerMock that counts background refreshes separately.
+type refresherMock struct {
+	*providerMock
+	refreshes int
+}
+
+func (rm *refresherMock) Refresh(name string) (types.Profile, error) {
+	rm.mu.Lock()
+	rm.refreshes++
+	rm.mu.Unlock()
+	return rm.Get(name)
+}
+
+// contextMock is a providerMock that returns the error of ctx if it's done before the delay.
+type contextMock struct {
+	*providerMock
+}
+
+func (cm *contextMock) GetContext(ctx context.Context, name string) (types.Profile, error) {
+	select {
+	case <-time.After(cm.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	return cm.providerMock.Get(name)
+}
+
+// prompterMock is a providerMock that could ask the user for input.
+type prompterMock struct {
+	*providerMock
+}
+
+func (pm *prompterMock) SetPrompter(prompter types.Prompter) {}
+
+type profileMock struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+func (pm *profileMock) Name() string             { return pm.name }
+func (pm *profileMock) Payload() []byte          { return pm.payload }
+func (pm *profileMock) Metadata() types.Metadata { return pm.metadata }
+
+func TestCacheStatic(t *testing.T) {
+	fn := filepath.Join(t.TempDir(), "credentials")
+	assert.NoError(t, os.WriteFile(fn, []byte("first"), 0600))
+
+	mock := &providerMock{files: []string{fn}, gets: map[string]int{}}
+	c := newCache(mock, time.Hour)
+	assert.Equal(t, "mock", c.Name())
+
+	for i := 0; i < 3; i++ {
+		profile, err := c.Get("static")
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("1"), profile.Payload())
+	}
+
+	_, err := c.Get("error")
+	assert.Error(t, err)
+
+	// Changing the underlying files invalidates the cache.
+	assert.NoError(t, os.Chtimes(fn, time.Now(), time.Now().Add(time.Minute)))
+	profile, err := c.Get("static")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), profile.Payload())
+	assert.Equal(t, 2, mock.count("static"))
+
+	// Without ttl static profiles aren't cached.
+	c = newCache(mock, 0)
+	c.Get("uncached")
+	c.Get("uncached")
+	assert.Equal(t, 2, mock.count("uncached"))
+}
+
+func TestCacheExpiring(t *testing.T) {
+	mock := &providerMock{lifetime: expiryWindow + 200*time.Millisecond, gets: map[string]int{}}
+	c := newCache(mock, time.Hour)
+
+	profile, err := c.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), profile.Payload())
+
+	// After 3/4 of the lifetime the cached profile is returned and refreshed in the background.
+	time.Sleep(160 * time.Millisecond)
+	profile, err = c.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1"), profile.Payload())
+
+	assert.Eventually(t, func() bool { return mock.count("session") == 2 }, time.Second, 10*time.Millisecond)
+	profile, err = c.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), profile.Payload())
+
+	// Credentials within the expiry window are never cached.
+	mock.lifetime = expiryWindow / 2
+	c.Get("short")
+	c.Get("short")
+	assert.Equal(t, 2, mock.count("short"))
+}
+
+func TestCacheConcurrent(t *testing.T) {
+	mock := &providerMock{delay: 50 * time.Millisecond, gets: map[string]int{}}
+	c := newCache(mock, time.Hour)
+
+	// Concurrent misses for the same profile only gets it once.
+	wg := sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			profile, err := c.Get("static")
+			assert.NoError(t, err)
+			assert.Equal(t, []byte("1"), profile.Payload())
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 1, mock.count("static"))
+
+	// Errors are returned to all waiting callers and aren't cached.
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := c.Get("error")
+			errs <- err
+		}()
+	}
+	assert.EqualError(t, <-errs, "mock error")
+	assert.EqualError(t, <-errs, "mock error")
+	assert.Empty(t, c.inflight)
+}
+
+func TestCacheContext(t *testing.T) {
+	mock := &contextMock{&providerMock{delay: 100 * time.Millisecond, gets: map[string]int{}}}
+	c := newCache(mock, time.Hour)
+
+	first, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 1)
+	go func() {
+		_, err := c.GetContext(first, "static")
+		errs <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Callers waiting for a fetch leaves when their own ctx is done.
+	waiting, stop := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer stop()
+	_, err := c.GetContext(waiting, "static")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// A fetch cancelled by the caller making it is retried by the callers waiting for it.
+	profiles := make(chan types.Profile, 1)
+	go func() {
+		profile, err := c.GetContext(context.Background(), "static")
+		assert.NoError(t, err)
+		profiles <- profile
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Equal(t, []byte("1"), (<-profiles).Payload())
+	assert.Equal(t, 1, mock.count("static"))
+}
+
+func TestCacheRefresher(t *testing.T) {
+	mock := &refresherMock{providerMock: &providerMock{lifetime: expiryWindow + 200*time.Millisecond, gets: map[string]int{}}}
+	c := newCache(mock, time.Hour)
+
+	_, err := c.Get("session")
+	assert.NoError(t, err)
+
+	// Profiles are refreshed in the background with Refresh instead of Get.
+	time.Sleep(160 * time.Millisecond)
+	_, err = c.Get("session")
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return mock.count("session") == 2 }, time.Second, 10*time.Millisecond)
+	mock.mu.Lock()
+	assert.Equal(t, 1, mock.refreshes)
+	mock.mu.Unlock()
+
+	// Providers that could ask the user aren't refreshed in the background, the profile is
+	// fetched again once it has expired.
+	prompter := &prompterMock{&providerMock{lifetime: expiryWindow + 200*time.Millisecond, gets: map[string]int{}}}
+	c = newCache(prompter, time.Hour)
+	_, err = c.Get("session")
+	assert.NoError(t, err)
+	time.Sleep(160 * time.Millisecond)
+	_, err = c.Get("session")
+	assert.NoError(t, err)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, 1, prompter.count("session"))
+
+	time.Sleep(50 * time.Millisecond)
+	profile, err := c.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("2"), profile.Payload())
+}
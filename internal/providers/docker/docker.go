@@ -0,0 +1,299 @@
+// Package docker is a provider that can be used by the providers package to
+// retrieve container registry credentials from the docker config file and
+// docker credential helpers.
+package docker
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Defaults used if not configured.
+const (
+	defaultHelperTimeout = 30 * time.Second
+	defaultProfile       = "$default"
+)
+
+// dockerHub is the server url docker uses for docker hub credentials.
+const dockerHub = "https://index.docker.io/v1/"
+
+// dockerHubHosts are the hosts that are aliases of docker hub.
+var dockerHubHosts = map[string]bool{
+	"docker.io":            true,
+	"index.docker.io":      true,
+	"registry-1.docker.io": true,
+}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Config        string `mapstructure:"config"`
+		HelperTimeout string `mapstructure:"helper-timeout"`
+	}{Config: defaultConfig()}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("docker: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	helperTimeout := defaultHelperTimeout
+	if data.HelperTimeout != "" {
+		var err error
+		if helperTimeout, err = time.ParseDuration(data.HelperTimeout); err != nil {
+			return nil, fmt.Errorf("docker: couldn't parse %s for %q. %w", "helper-timeout", name, err)
+		}
+	}
+
+	return &Provider{
+		name:          name,
+		config:        data.Config,
+		helperTimeout: helperTimeout,
+	}, nil
+}
+
+// defaultConfig returns config.json in DOCKER_CONFIG or ~/.docker.
+func defaultConfig() string {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return filepath.Join(dir, "config.json")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".docker", "config.json")
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	config        string
+	helperTimeout time.Duration
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve the credentials of registry host name. Profile $default is docker hub.
+// The credential helper of the registry is used first, then the credentials store and
+// last the credentials stored in the config file.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	cfg, err := readConfig(p.config)
+	if err != nil {
+		return nil, fmt.Errorf("docker: couldn't read config for %q. %w", p.Name(), err)
+	}
+
+	host := normalize(name)
+	if name == defaultProfile {
+		host = normalize(dockerHub)
+	}
+	serverURL := host
+	if host == normalize(dockerHub) {
+		serverURL = dockerHub
+	}
+
+	entry := cfg.entry(host)
+
+	helper := cfg.CredHelpers[host]
+	if helper == "" {
+		helper = cfg.CredsStore
+	}
+
+	var creds *credentials
+	var helperErr error
+	if helper != "" {
+		creds, helperErr = p.runHelper(helper, serverURL)
+	}
+	if creds == nil && entry != nil {
+		if creds, err = entry.credentials(); err != nil {
+			return nil, fmt.Errorf("docker: couldn't read credentials for %q from %q. %w", name, p.Name(), err)
+		}
+	}
+	switch {
+	case creds == nil && helperErr != nil:
+		return nil, fmt.Errorf("docker: couldn't get credentials for %q from %q. %w", name, p.Name(), helperErr)
+	case creds == nil:
+		return nil, fmt.Errorf("docker: no credentials for registry %q in %q", host, p.Name())
+	}
+
+	fields := map[string]interface{}{"registry": serverURL}
+	if creds.username != "" {
+		fields["username"] = creds.username
+	}
+	if creds.password != "" {
+		fields["password"] = creds.password
+		fields["auth"] = base64.StdEncoding.EncodeToString([]byte(creds.username + ":" + creds.password))
+	}
+	if creds.identityToken != "" {
+		fields["identityToken"] = creds.identityToken
+	}
+	if creds.registryToken != "" {
+		fields["registryToken"] = creds.registryToken
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("docker: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Source: fmt.Sprintf("docker: %s", creds.source),
+			Kind:   types.KindStatic,
+		},
+	}, nil
+}
+
+// Files returns the docker config file.
+func (p *Provider) Files() []string {
+	return []string{p.config}
+}
+
+// credentials is the credentials of a registry.
+type credentials struct {
+	username      string
+	password      string
+	identityToken string
+	registryToken string
+	source        string
+}
+
+// config is the content of the docker config file.
+type config struct {
+	Auths       map[string]*authEntry `json:"auths"`
+	CredsStore  string                `json:"credsStore"`
+	CredHelpers map[string]string     `json:"credHelpers"`
+
+	file string
+}
+
+// authEntry is a registry in auths of the docker config file.
+type authEntry struct {
+	Auth          string `json:"auth"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	IdentityToken string `json:"identitytoken"`
+	RegistryToken string `json:"registrytoken"`
+
+	file string
+}
+
+// credentials returns the credentials of e. Returns nil if e only marks that the
+// credentials are stored by a helper.
+func (e *authEntry) credentials() (*credentials, error) {
+	creds := &credentials{
+		username:      e.Username,
+		password:      e.Password,
+		identityToken: e.IdentityToken,
+		registryToken: e.RegistryToken,
+		source:        e.file,
+	}
+
+	if e.Auth != "" {
+		raw, err := base64.StdEncoding.DecodeString(e.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't base64 decode auth. %w", err)
+		}
+		userPass := strings.SplitN(string(raw), ":", 2)
+		if len(userPass) != 2 {
+			return nil, fmt.Errorf("auth isn't in format username:password")
+		}
+		creds.username, creds.password = userPass[0], userPass[1]
+	}
+
+	if creds.password == "" && creds.identityToken == "" && creds.registryToken == "" {
+		return nil, nil
+	}
+	return creds, nil
+}
+
+// entry returns the auths entry for host or nil if there is none.
+func (c *config) entry(host string) *authEntry {
+	for key, entry := range c.Auths {
+		if normalize(key) == host && entry != nil {
+			entry.file = c.file
+			return entry
+		}
+	}
+	return nil
+}
+
+// readConfig will read the docker config from file fn. A missing file is treated as empty.
+func readConfig(fn string) (*config, error) {
+	cfg := &config{file: fn}
+
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("couldn't read file %q. %w", fn, err)
+	}
+
+	if err := json.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("couldn't json unmarshal file %q. %w", fn, err)
+	}
+
+	// Registries in credHelpers are matched by host as well.
+	helpers := map[string]string{}
+	for key, helper := range cfg.CredHelpers {
+		helpers[normalize(key)] = helper
+	}
+	cfg.CredHelpers = helpers
+
+	return cfg, nil
+}
+
+// normalize returns the host of registry, the same way as docker matches auths entries.
+// Aliases of docker hub are normalized to index.docker.io.
+func normalize(registry string) string {
+	host := strings.ToLower(registry)
+	if strings.Contains(host, "://") {
+		if u, err := url.Parse(host); err == nil && u.Host != "" {
+			host = u.Host
+		}
+	}
+	host = strings.SplitN(host, "/", 2)[0]
+
+	if dockerHubHosts[host] {
+		return "index.docker.io"
+	}
+	return host
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
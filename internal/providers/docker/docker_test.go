@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	// The credential helpers in testdata are found through PATH.
+	dir, err := filepath.Abs("./testdata")
+	assert.NoError(t, err)
+	defer os.Setenv("PATH", os.Getenv("PATH"))
+	os.Setenv("PATH", dir+string(filepath.ListSeparator)+os.Getenv("PATH"))
+
+	provider, err := Create("docker", map[string]interface{}{"config": "./testdata/config.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "docker", provider.Name())
+	assert.Equal(t, []string{"./testdata/config.json"}, provider.Files())
+
+	for name, expected := range map[string]map[string]string{
+		"$default": {
+			"registry": "https://index.docker.io/v1/", "username": "hub-user", "password": "hub-pass",
+			"auth": "aHViLXVzZXI6aHViLXBhc3M=",
+		},
+		"docker.io": {
+			"registry": "https://index.docker.io/v1/", "username": "hub-user", "password": "hub-pass",
+			"auth": "aHViLXVzZXI6aHViLXBhc3M=",
+		},
+		"registry.example.com": {
+			"registry": "registry.example.com", "username": "user", "password": "pass", "auth": "dXNlcjpwYXNz",
+		},
+		"https://token.example.com/v2/": {
+			"registry": "token.example.com", "identityToken": "file-token",
+		},
+		"helper.example.com": {
+			"registry": "helper.example.com", "username": "helper-user", "password": "helper-secret",
+			"auth": "aGVscGVyLXVzZXI6aGVscGVyLXNlY3JldA==",
+		},
+		"ecr.example.com": {
+			"registry": "ecr.example.com", "identityToken": "identity-token",
+		},
+		"store.example.com": {
+			"registry": "store.example.com", "username": "store-user", "password": "store-secret",
+			"auth": "c3RvcmUtdXNlcjpzdG9yZS1zZWNyZXQ=",
+		},
+		"fallback.example.com": {
+			"registry": "fallback.example.com", "username": "fallback-user", "password": "fallback-pass",
+			"auth": "ZmFsbGJhY2stdXNlcjpmYWxsYmFjay1wYXNz",
+		},
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindStatic, profile.Metadata().Kind)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields, name)
+	}
+
+	profile, err := provider.Get("helper.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "docker: docker-credential-helper-test", profile.Metadata().Source)
+	profile, err = provider.Get("registry.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "docker: ./testdata/config.json", profile.Metadata().Source)
+
+	// Line breaks would be sent to the credentials store as another server url.
+	for _, name := range []string{"broken.example.com", "unknown.example.com", "store.example.com\nother.example.com"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestMissingConfig(t *testing.T) {
+	provider, err := Create("docker", map[string]interface{}{"config": "./testdata/missing.json"})
+	assert.NoError(t, err)
+	_, err = provider.Get("registry.example.com")
+	assert.EqualError(t, err, `docker: no credentials for registry "registry.example.com" in "docker"`)
+}
+
+func TestNormalize(t *testing.T) {
+	for registry, expected := range map[string]string{
+		"https://index.docker.io/v1/": "index.docker.io",
+		"registry-1.docker.io":        "index.docker.io",
+		"Registry.Example.com:5000":   "registry.example.com:5000",
+		"http://registry.example.com": "registry.example.com",
+		"registry.example.com/path":   "registry.example.com",
+	} {
+		assert.Equal(t, expected, normalize(registry), registry)
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"config": []string{"config.json"}},
+		{"helper-timeout": "forever"},
+	} {
+		_, err := Create("docker", config)
+		assert.Error(t, err, config)
+	}
+}
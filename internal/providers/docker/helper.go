@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// tokenUsername is the username credential helpers returns for identity tokens.
+const tokenUsername = "<token>"
+
+// runHelper will get the credentials of serverURL from docker-credential-<helper>
+// using the docker credential helper protocol. Server urls with line breaks or nul
+// characters are rejected since the helper reads the server url from stdin.
+func (p *Provider) runHelper(helper string, serverURL string) (*credentials, error) {
+	command := "docker-credential-" + helper
+	if strings.ContainsAny(serverURL, "\n\r\x00") {
+		return nil, fmt.Errorf("invalid server url %q for %q", serverURL, command)
+	}
+
+	cmd := &process.Command{
+		Args:    []string{command, "get"},
+		Stdin:   []byte(serverURL),
+		Timeout: p.helperTimeout,
+	}
+
+	out, err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	raw := &struct {
+		ServerURL string `json:"ServerURL"`
+		Username  string `json:"Username"`
+		Secret    string `json:"Secret"`
+	}{}
+	if err := json.Unmarshal(out, raw); err != nil {
+		return nil, fmt.Errorf("couldn't json unmarshal output of %q. %w", command, err)
+	}
+	if raw.Secret == "" {
+		return nil, fmt.Errorf("no secret returned by %q for %q", command, serverURL)
+	}
+
+	creds := &credentials{username: raw.Username, password: raw.Secret, source: command}
+	if raw.Username == tokenUsername {
+		creds.username, creds.password, creds.identityToken = "", "", raw.Secret
+	}
+	return creds, nil
+}
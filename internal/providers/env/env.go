@@ -0,0 +1,188 @@
+// Package env is a provider that can be used by the providers package to
+// retrieve secrets from environmental variables, for example when running in ci.
+package env
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// defaultPrefix is the prefix of the environmental variables if not configured.
+const defaultPrefix = "PMCREDS"
+
+// validName matches the names of profiles that are read from prefixed variables. Names are
+// lower case with - as separator so that no two profiles are read from the same variables.
+var validName = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Prefix   string                       `mapstructure:"prefix"`
+		Required []string                     `mapstructure:"required"`
+		Profiles map[string]map[string]string `mapstructure:"profiles"`
+	}{Prefix: defaultPrefix}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("env: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	if data.Prefix == "" {
+		return nil, fmt.Errorf("env: no %s set for %q", "prefix", name)
+	}
+
+	for profile, fields := range data.Profiles {
+		for field, variable := range fields {
+			if variable == "" {
+				return nil, fmt.Errorf("env: no variable set for field %q in profile %q for %q", field, profile, name)
+			}
+		}
+	}
+
+	return &Provider{
+		name:     name,
+		prefix:   data.Prefix,
+		required: data.Required,
+		profiles: data.Profiles,
+	}, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	prefix   string
+	required []string
+	profiles map[string]map[string]string
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve profile name from the environmental variables. Profiles configured
+// with fields are read from the variables set for each field, all which are required.
+// Other profiles are read from all variables starting with <prefix>_<PROFILE>__.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	fields := map[string]string{}
+	source := ""
+
+	switch mapping, ok := p.profiles[name]; ok {
+	case true:
+		missing := []string{}
+		for field, variable := range mapping {
+			value, ok := os.LookupEnv(variable)
+			if !ok || value == "" {
+				missing = append(missing, variable)
+				continue
+			}
+			fields[field] = value
+		}
+		if len(missing) > 0 {
+			sort.Strings(missing)
+			return nil, fmt.Errorf("env: missing variables %s for %q from %q", strings.Join(missing, ", "), name, p.Name())
+		}
+		source = "env: fields"
+
+	case false:
+		if !validName.MatchString(name) {
+			return nil, fmt.Errorf("env: invalid profile %q for %q. must be lower case letters and digits separated by -", name, p.Name())
+		}
+
+		prefix := p.variablePrefix(name)
+		for _, kv := range os.Environ() {
+			pair := strings.SplitN(kv, "=", 2)
+			if len(pair) != 2 || !strings.HasPrefix(pair[0], prefix) || pair[0] == prefix {
+				continue
+			}
+			fields[camelCase(strings.TrimPrefix(pair[0], prefix))] = pair[1]
+		}
+		if len(fields) == 0 {
+			return nil, fmt.Errorf("env: no variables with prefix %q for %q from %q", prefix, name, p.Name())
+		}
+
+		missing := []string{}
+		for _, field := range p.required {
+			if fields[field] == "" {
+				missing = append(missing, prefix+snakeCase(field))
+			}
+		}
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("env: missing variables %s for %q from %q", strings.Join(missing, ", "), name, p.Name())
+		}
+		source = fmt.Sprintf("env: %s*", prefix)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("env: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:     name,
+		payload:  payload,
+		metadata: types.Metadata{Source: source, Kind: types.KindSecret},
+	}, nil
+}
+
+// variablePrefix returns the prefix of the variables for profile name, which must
+// be valid. The profile name is upper cased with - replaced by _ and separated from
+// the fields by __, so profile my doesn't read the variables of profile my-api.
+func (p *Provider) variablePrefix(name string) string {
+	profile := strings.ToUpper(strings.Replace(name, "-", "_", -1))
+	return p.prefix + "_" + profile + "__"
+}
+
+// camelCase converts SNAKE_CASE to camelCase.
+func camelCase(s string) string {
+	words := strings.Split(strings.ToLower(s), "_")
+	for i := 1; i < len(words); i++ {
+		if words[i] != "" {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+	return strings.Join(words, "")
+}
+
+// snakeCase converts camelCase to SNAKE_CASE.
+func snakeCase(s string) string {
+	b := &strings.Builder{}
+	for i, r := range s {
+		if i > 0 && unicode.IsUpper(r) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,153 @@
+package env
+
+import (
+	"os"
+	"testing"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var variables = map[string]string{
+	"PMCREDS_GITHUB__TOKEN":          "ghp_secret",
+	"PMCREDS_MY_API__ACCESS_KEY":     "access",
+	"PMCREDS_MY_API__SECRET_KEY":     "secret",
+	"PMCREDS_MY__TOKEN":              "my_secret",
+	"PMCREDS_PARTIAL__ACCESS_KEY":    "access",
+	"CI_GITHUB__TOKEN":               "ci_secret",
+	"PMCREDS_TEST_DATABASE_USER":     "admin",
+	"PMCREDS_TEST_DATABASE_PASSWORD": "db-secret",
+	"PMCREDS_TEST_EMPTY":             "",
+}
+
+var tests = []struct {
+	name          string
+	config        map[string]interface{}
+	profiles      map[string]string
+	profilesError []string
+	err           bool
+}{
+	{
+		name:   "env-prefix",
+		config: map[string]interface{}{},
+		profiles: map[string]string{
+			"github": `{"token":"ghp_secret"}`,
+			"my-api": `{"accessKey":"access","secretKey":"secret"}`,
+			"my":     `{"token":"my_secret"}`,
+		},
+		profilesError: []string{"notexist", "my.api", "my_api", "MY-API", "my--api", "-my", ""},
+	},
+	{
+		name: "env-required",
+		config: map[string]interface{}{
+			"required": []string{"accessKey", "secretKey"},
+		},
+		profiles: map[string]string{
+			"my-api": `{"accessKey":"access","secretKey":"secret"}`,
+		},
+		profilesError: []string{"partial", "github"},
+	},
+	{
+		name:   "env-custom-prefix",
+		config: map[string]interface{}{"prefix": "CI"},
+		profiles: map[string]string{
+			"github": `{"token":"ci_secret"}`,
+		},
+		profilesError: []string{"my-api"},
+	},
+	{
+		name: "env-fields",
+		config: map[string]interface{}{
+			"profiles": map[string]interface{}{
+				"database": map[string]interface{}{
+					"username": "PMCREDS_TEST_DATABASE_USER",
+					"password": "PMCREDS_TEST_DATABASE_PASSWORD",
+				},
+				"missing": map[string]interface{}{
+					"username": "PMCREDS_TEST_DATABASE_USER",
+					"password": "PMCREDS_TEST_NOT_SET",
+				},
+				"empty": map[string]interface{}{
+					"value": "PMCREDS_TEST_EMPTY",
+				},
+			},
+		},
+		profiles: map[string]string{
+			"database": `{"password":"db-secret","username":"admin"}`,
+			"github":   `{"token":"ghp_secret"}`,
+		},
+		profilesError: []string{"missing", "empty"},
+	},
+	{
+		name: "env-fields-no-variable",
+		config: map[string]interface{}{
+			"profiles": map[string]interface{}{
+				"database": map[string]interface{}{"username": ""},
+			},
+		},
+		err: true,
+	},
+	{
+		name:   "env-empty-prefix",
+		config: map[string]interface{}{"prefix": ""},
+		err:    true,
+	},
+	{
+		name:   "env-error",
+		config: map[string]interface{}{"required": "token"},
+		err:    true,
+	},
+}
+
+func TestProvider(t *testing.T) {
+	for key, value := range variables {
+		os.Setenv(key, value)
+		defer os.Unsetenv(key)
+	}
+
+	for _, test := range tests {
+		provider, err := Create(test.name, test.config)
+		switch test.err {
+		case true:
+			assert.Error(t, err)
+			continue
+		case false:
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, test.name, provider.Name())
+
+		for name, payload := range test.profiles {
+			profile, err := provider.Get(name)
+			assert.NoError(t, err, test.name)
+			if err != nil {
+				continue
+			}
+			assert.Equal(t, name, profile.Name())
+			assert.JSONEq(t, payload, string(profile.Payload()))
+			assert.Equal(t, types.KindSecret, profile.Metadata().Kind)
+		}
+
+		for _, name := range test.profilesError {
+			_, err := provider.Get(name)
+			assert.Error(t, err, test.name)
+		}
+	}
+}
+
+func TestMissingError(t *testing.T) {
+	os.Setenv("PMCREDS_PARTIAL__ACCESS_KEY", "access")
+	defer os.Unsetenv("PMCREDS_PARTIAL__ACCESS_KEY")
+
+	provider, err := Create("env", map[string]interface{}{"required": []string{"accessKey", "secretKey"}})
+	assert.NoError(t, err)
+
+	_, err = provider.Get("partial")
+	assert.EqualError(t, err, `env: missing variables PMCREDS_PARTIAL__SECRET_KEY for "partial" from "env"`)
+}
+
+func TestCase(t *testing.T) {
+	assert.Equal(t, "accessKeyId", camelCase("ACCESS_KEY_ID"))
+	assert.Equal(t, "token", camelCase("TOKEN"))
+	assert.Equal(t, "ACCESS_KEY_ID", snakeCase("accessKeyId"))
+	assert.Equal(t, "TOKEN", snakeCase("token"))
+}
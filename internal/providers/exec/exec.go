@@ -0,0 +1,230 @@
+// Package exec is a provider that can be used by the providers package to
+// retrieve credentials by running an external command.
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/process"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Output formats supported.
+const (
+	FormatJSON     = "json"
+	FormatKeyValue = "key-value"
+)
+
+// defaultTimeout is how long the command may run if not configured.
+const defaultTimeout = 30 * time.Second
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Command []string          `mapstructure:"command"`
+		Env     map[string]string `mapstructure:"env"`
+		Dir     string            `mapstructure:"dir"`
+		Timeout string            `mapstructure:"timeout"`
+		Format  string            `mapstructure:"format"`
+	}{Format: FormatJSON}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("exec: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	if len(data.Command) == 0 {
+		return nil, fmt.Errorf("exec: no %s set for %q", "command", name)
+	}
+
+	format := strings.ToLower(data.Format)
+	if format != FormatJSON && format != FormatKeyValue {
+		return nil, fmt.Errorf("exec: invalid %s %q for %q", "format", data.Format, name)
+	}
+
+	timeout := defaultTimeout
+	if data.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(data.Timeout); err != nil {
+			return nil, fmt.Errorf("exec: couldn't parse %s for %q. %w", "timeout", name, err)
+		}
+	}
+
+	args := []*template.Template{}
+	for _, arg := range data.Command {
+		tmpl, err := template.New("command").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("exec: couldn't parse command %q for %q. %w", arg, name, err)
+		}
+		args = append(args, tmpl)
+	}
+
+	env := map[string]*template.Template{}
+	for key, value := range data.Env {
+		tmpl, err := template.New("env").Option("missingkey=error").Parse(value)
+		if err != nil {
+			return nil, fmt.Errorf("exec: couldn't parse env %q for %q. %w", key, name, err)
+		}
+		env[key] = tmpl
+	}
+
+	return &Provider{
+		name:    name,
+		command: data.Command,
+		args:    args,
+		env:     env,
+		dir:     data.Dir,
+		timeout: timeout,
+		format:  format,
+	}, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	command []string
+	args    []*template.Template
+	env     map[string]*template.Template
+	dir     string
+	timeout time.Duration
+	format  string
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will run the command with the profile name substituted and return its output as
+// profile name. Output in key-value format will be converted to json.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	if err := validName(name); err != nil {
+		return nil, fmt.Errorf("exec: invalid profile %q for %q. %w", name, p.Name(), err)
+	}
+
+	vars := map[string]string{"Profile": name, "Provider": p.name}
+
+	cmd := &process.Command{Dir: p.dir, Timeout: p.timeout}
+	for _, arg := range p.args {
+		value, err := execute(arg, vars)
+		if err != nil {
+			return nil, fmt.Errorf("exec: couldn't create command for %q from %q. %w", name, p.Name(), err)
+		}
+		cmd.Args = append(cmd.Args, value)
+	}
+	for key, tmpl := range p.env {
+		value, err := execute(tmpl, vars)
+		if err != nil {
+			return nil, fmt.Errorf("exec: couldn't create env %q for %q from %q. %w", key, name, p.Name(), err)
+		}
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
+	}
+
+	out, err := cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("exec: couldn't get credentials for %q from %q. %w", name, p.Name(), err)
+	}
+
+	fields := map[string]interface{}{}
+	payload := &bytes.Buffer{}
+	switch p.format {
+	case FormatJSON:
+		if err := json.Unmarshal(out, &fields); err != nil {
+			return nil, fmt.Errorf("exec: couldn't json unmarshal output for %q from %q. %w", name, p.Name(), err)
+		}
+		if err := json.Compact(payload, out); err != nil {
+			return nil, fmt.Errorf("exec: couldn't compact output for %q from %q. %w", name, p.Name(), err)
+		}
+
+	case FormatKeyValue:
+		if fields, err = parseKeyValue(out); err != nil {
+			return nil, fmt.Errorf("exec: couldn't parse output for %q from %q. %w", name, p.Name(), err)
+		}
+		raw, _ := json.Marshal(fields)
+		payload.Write(raw)
+	}
+
+	metadata := types.Metadata{Source: fmt.Sprintf("exec: %s", p.command[0]), Kind: types.KindSecret}
+	if expiration, ok := fields["expiration"].(string); ok && expiration != "" {
+		if metadata.Expires, err = time.Parse(time.RFC3339, expiration); err != nil {
+			return nil, fmt.Errorf("exec: couldn't parse expiration for %q from %q. %w", name, p.Name(), err)
+		}
+	}
+
+	return &Profile{name: name, payload: payload.Bytes(), metadata: metadata}, nil
+}
+
+// validName returns an error if name could be taken as an option or contains
+// control characters when substituted into the command.
+func validName(name string) error {
+	if strings.HasPrefix(name, "-") {
+		return fmt.Errorf("profile can't start with %q", "-")
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("profile can't contain control characters")
+		}
+	}
+	return nil
+}
+
+// execute will execute tmpl with vars and return the result.
+func execute(tmpl *template.Template, vars map[string]string) (string, error) {
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, vars); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseKeyValue parses lines of key=value. Empty lines and lines starting with # are ignored.
+func parseKeyValue(out []byte) (map[string]interface{}, error) {
+	fields := map[string]interface{}{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		kv := strings.SplitN(text, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) == "" {
+			return nil, fmt.Errorf("line %d isn't in format key=value", line)
+		}
+		fields[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+
+	return fields, scanner.Err()
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
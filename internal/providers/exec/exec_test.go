@@ -0,0 +1,118 @@
+package exec
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var tests = []struct {
+	name          string
+	config        map[string]interface{}
+	profiles      map[string]string
+	expires       map[string]time.Time
+	profilesError []string
+	err           bool
+}{
+	{
+		name: "exec-json",
+		config: map[string]interface{}{
+			"command": []interface{}{"sh", "tool.sh", "{{.Profile}}", "{{.Provider}}"},
+			"dir":     "./testdata",
+			"env":     map[string]interface{}{"TOOL_PREFIX": "{{.Profile}}"},
+		},
+		profiles: map[string]string{
+			"json":     `{"username":"exec-json","password":"json-secret"}`,
+			"expiring": `{"token":"token","expiration":"2100-01-01T00:00:00Z"}`,
+		},
+		expires: map[string]time.Time{
+			"expiring": time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		profilesError: []string{"array", "key-value", "notexist", "json; rm -rf /", "-json", "json\n", "js\x00on"},
+	},
+	{
+		name: "exec-key-value",
+		config: map[string]interface{}{
+			"command": []string{"sh", "./testdata/tool.sh", "key-value", "{{.Profile}}"},
+			"format":  "key-value",
+			"timeout": "5s",
+		},
+		profiles: map[string]string{
+			"user": `{"password":"a=b","username":"user"}`,
+		},
+	},
+	{
+		name:   "exec-no-command",
+		config: map[string]interface{}{},
+		err:    true,
+	},
+	{
+		name: "exec-format",
+		config: map[string]interface{}{
+			"command": []string{"echo"},
+			"format":  "yaml",
+		},
+		err: true,
+	},
+	{
+		name: "exec-timeout",
+		config: map[string]interface{}{
+			"command": []string{"echo"},
+			"timeout": "soon",
+		},
+		err: true,
+	},
+	{
+		name: "exec-template",
+		config: map[string]interface{}{
+			"command": []string{"echo", "{{.Profile"},
+		},
+		err: true,
+	},
+}
+
+func TestLoad(t *testing.T) {
+	for _, test := range tests {
+		provider, err := Create(test.name, test.config)
+		switch test.err {
+		case true:
+			assert.Error(t, err)
+			continue
+		case false:
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, test.name, provider.Name())
+
+		for name, payload := range test.profiles {
+			profile, err := provider.Get(name)
+			assert.NoError(t, err)
+			assert.Equal(t, name, profile.Name())
+			assert.Equal(t, payload, string(profile.Payload()))
+			assert.Equal(t, types.KindSecret, profile.Metadata().Kind)
+			assert.Equal(t, test.expires[name], profile.Metadata().Expires.UTC())
+		}
+
+		for _, name := range test.profilesError {
+			_, err := provider.Get(name)
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestValidName(t *testing.T) {
+	for name, valid := range map[string]bool{
+		"dev":       true,
+		"team/prod": true,
+		"my-api":    true,
+		"--help":    false,
+		"-v":        false,
+		"dev\nprod": false,
+		"dev\r":     false,
+		"dev\x00":   false,
+		"dev\t":     false,
+	} {
+		assert.Equal(t, valid, validName(name) == nil, name)
+	}
+}
@@ -0,0 +1,19 @@
+#!/bin/sh
+case "$1" in
+json)
+	printf '{\n  "username": "%s",\n  "password": "%s-secret"\n}\n' "$2" "$TOOL_PREFIX"
+	;;
+expiring)
+	echo '{"token": "token", "expiration": "2100-01-01T00:00:00Z"}'
+	;;
+key-value)
+	printf '# generated\nusername = %s\n\npassword=a=b\n' "$2"
+	;;
+array)
+	echo '["not", "an", "object"]'
+	;;
+*)
+	echo "unknown profile $2" >&2
+	exit 1
+	;;
+esac
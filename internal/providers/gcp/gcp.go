@@ -0,0 +1,356 @@
+// Package gcp is a provider that can be used by the providers package to
+// retrieve google cloud access tokens and id tokens using service account
+// keys or application default credentials.
+package gcp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/jwt"
+	"github.com/nuttmeister/pm-creds/internal/oauth"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Defaults used if not configured.
+const (
+	defaultTokenEndpoint = "https://oauth2.googleapis.com/token"
+	defaultScope         = "https://www.googleapis.com/auth/cloud-platform"
+	defaultTimeout       = 30 * time.Second
+	defaultProfile       = "$default"
+)
+
+// Credential types supported.
+const (
+	typeServiceAccount = "service_account"
+	typeAuthorizedUser = "authorized_user"
+)
+
+// assertionLifetime is how long the signed assertion is valid.
+const assertionLifetime = time.Hour
+
+// profile is the configuration of a single profile.
+type profile struct {
+	KeyFile  string   `mapstructure:"key-file"`
+	Scopes   []string `mapstructure:"scopes"`
+	Audience string   `mapstructure:"audience"`
+	Subject  string   `mapstructure:"subject"`
+}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		TokenEndpoint string              `mapstructure:"token-endpoint"`
+		Credentials   string              `mapstructure:"credentials"`
+		Scopes        []string            `mapstructure:"scopes"`
+		Timeout       string              `mapstructure:"timeout"`
+		Profiles      map[string]*profile `mapstructure:"profiles"`
+	}{
+		Credentials: defaultCredentials(),
+		Scopes:      []string{defaultScope},
+	}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("gcp: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	if data.TokenEndpoint != "" {
+		if _, err := url.ParseRequestURI(data.TokenEndpoint); err != nil {
+			return nil, fmt.Errorf("gcp: couldn't parse %s for %q. %w", "token-endpoint", name, err)
+		}
+	}
+
+	timeout := defaultTimeout
+	if data.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(data.Timeout); err != nil {
+			return nil, fmt.Errorf("gcp: couldn't parse %s for %q. %w", "timeout", name, err)
+		}
+	}
+
+	for profileName, cfg := range data.Profiles {
+		if cfg.KeyFile == "" && profileName != defaultProfile {
+			return nil, fmt.Errorf("gcp: no key-file set in profile %q for %q", profileName, name)
+		}
+	}
+
+	return &Provider{
+		name:          name,
+		tokenEndpoint: data.TokenEndpoint,
+		credentials:   data.Credentials,
+		scopes:        data.Scopes,
+		profiles:      data.Profiles,
+		client:        &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// defaultCredentials returns the application default credentials file.
+func defaultCredentials() string {
+	if fn := os.Getenv("GOOGLE_APPLICATION_CREDENTIALS"); fn != "" {
+		return fn
+	}
+
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("APPDATA"), "gcloud", "application_default_credentials.json")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gcloud", "application_default_credentials.json")
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	tokenEndpoint string
+	credentials   string
+	scopes        []string
+	profiles      map[string]*profile
+	client        *http.Client
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve an access token for profile name, or an id token if the profile has an audience.
+// Profile $default uses the application default credentials.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	cfg, ok := p.profiles[name]
+	switch {
+	case !ok && name == defaultProfile:
+		cfg = &profile{}
+	case !ok:
+		return nil, fmt.Errorf("gcp: no profile %q in %q", name, p.Name())
+	}
+
+	fn := cfg.KeyFile
+	if fn == "" {
+		fn = p.credentials
+	}
+	creds, err := readCredentials(fn)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: couldn't get credentials for %q from %q. %w", name, p.Name(), err)
+	}
+
+	scopes := cfg.Scopes
+	if len(scopes) == 0 {
+		scopes = p.scopes
+	}
+
+	var token *oauth.Token
+	switch creds.Type {
+	case typeServiceAccount:
+		token, err = p.serviceAccountToken(creds, scopes, cfg.Audience, cfg.Subject)
+	case typeAuthorizedUser:
+		token, err = p.authorizedUserToken(creds)
+	default:
+		err = fmt.Errorf("unsupported credentials type %q in %q", creds.Type, fn)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("gcp: couldn't get token for %q from %q. %w", name, p.Name(), err)
+	}
+
+	fields := map[string]interface{}{}
+	expires := token.Expires
+	switch cfg.Audience {
+	case "":
+		if token.AccessToken == "" {
+			return nil, fmt.Errorf("gcp: no access token returned for %q from %q", name, p.Name())
+		}
+		fields["accessToken"] = token.AccessToken
+		fields["tokenType"] = token.TokenType
+
+	default:
+		if token.IDToken == "" {
+			return nil, fmt.Errorf("gcp: no id token returned for %q from %q", name, p.Name())
+		}
+		fields["idToken"] = token.IDToken
+		if expires, err = idTokenExpiry(token.IDToken); err != nil {
+			return nil, fmt.Errorf("gcp: couldn't read id token for %q from %q. %w", name, p.Name(), err)
+		}
+	}
+	if !expires.IsZero() {
+		fields["expiration"] = expires.UTC().Format(time.RFC3339)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("gcp: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Expires: expires,
+			Source:  fmt.Sprintf("gcp: %s", creds.identity()),
+			Kind:    types.KindSession,
+		},
+	}, nil
+}
+
+// Files returns the key files and application default credentials used.
+func (p *Provider) Files() []string {
+	files := []string{}
+	if p.credentials != "" {
+		files = append(files, p.credentials)
+	}
+	for _, cfg := range p.profiles {
+		if cfg.KeyFile != "" {
+			files = append(files, cfg.KeyFile)
+		}
+	}
+	return files
+}
+
+// credentials is a service account key or application default credentials file.
+type credentials struct {
+	Type string `json:"type"`
+
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// identity returns the identity of the credentials.
+func (c *credentials) identity() string {
+	if c.ClientEmail != "" {
+		return c.ClientEmail
+	}
+	return c.ClientID
+}
+
+// readCredentials will read credentials from file fn.
+func readCredentials(fn string) (*credentials, error) {
+	if fn == "" {
+		return nil, fmt.Errorf("no credentials file set")
+	}
+
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read credentials file %q. %w", fn, err)
+	}
+
+	creds := &credentials{}
+	if err := json.Unmarshal(raw, creds); err != nil {
+		return nil, fmt.Errorf("couldn't json unmarshal credentials file %q. %w", fn, err)
+	}
+
+	return creds, nil
+}
+
+// endpoint returns the token endpoint to use for creds.
+func (p *Provider) endpoint(creds *credentials) string {
+	switch {
+	case p.tokenEndpoint != "":
+		return p.tokenEndpoint
+	case creds.TokenURI != "":
+		return creds.TokenURI
+	default:
+		return defaultTokenEndpoint
+	}
+}
+
+// serviceAccountToken will sign an assertion with the service account key and exchange it for
+// an access token with scopes. If audience is set an id token for audience is requested instead.
+func (p *Provider) serviceAccountToken(creds *credentials, scopes []string, audience string, subject string) (*oauth.Token, error) {
+	key, err := jwt.ParsePrivateKey([]byte(creds.PrivateKey))
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := p.endpoint(creds)
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss": creds.ClientEmail,
+		"aud": endpoint,
+		"iat": now.Unix(),
+		"exp": now.Add(assertionLifetime).Unix(),
+	}
+	switch audience {
+	case "":
+		claims["scope"] = strings.Join(scopes, " ")
+	default:
+		claims["target_audience"] = audience
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+
+	assertion, err := jwt.Sign(jwt.RS256, key, map[string]interface{}{"kid": creds.PrivateKeyID}, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {assertion},
+	}
+	return oauth.Request(p.client, endpoint, form, nil)
+}
+
+// authorizedUserToken will exchange the refresh token of user credentials for an access token.
+// The id token is returned as well if the user consented to the openid scope.
+func (p *Provider) authorizedUserToken(creds *credentials) (*oauth.Token, error) {
+	form := url.Values{
+		"grant_type":    {"refresh_token"},
+		"client_id":     {creds.ClientID},
+		"client_secret": {creds.ClientSecret},
+		"refresh_token": {creds.RefreshToken},
+	}
+	return oauth.Request(p.client, p.endpoint(creds), form, nil)
+}
+
+// idTokenExpiry returns the expiry of the id token.
+func idTokenExpiry(token string) (time.Time, error) {
+	claims, err := jwt.Claims(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return time.Time{}, fmt.Errorf("id token has no exp claim")
+	}
+	return time.Unix(int64(exp), 0), nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,154 @@
+package gcp
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/jwt"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// tokenStub is a stand-in for the google token endpoint that verifies assertions with key.
+func tokenStub(t *testing.T, key *rsa.PublicKey) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.PostForm.Get("grant_type") {
+		case "refresh_token":
+			if r.PostForm.Get("refresh_token") != "refresh" || r.PostForm.Get("client_secret") != "user-secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant"}`)
+				return
+			}
+			fmt.Fprint(w, `{"access_token":"user-token","token_type":"Bearer","expires_in":3599}`)
+
+		case "urn:ietf:params:oauth:grant-type:jwt-bearer":
+			parts := strings.Split(r.PostForm.Get("assertion"), ".")
+			assert.Len(t, parts, 3)
+			signature, _ := base64.RawURLEncoding.DecodeString(parts[2])
+			digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":"invalid_grant","error_description":"Invalid JWT Signature."}`)
+				return
+			}
+
+			claims, _ := jwt.Claims(r.PostForm.Get("assertion"))
+			assert.Equal(t, "sa@project.iam.gserviceaccount.com", claims["iss"])
+			assert.Equal(t, "http://"+r.Host, claims["aud"])
+
+			if audience, ok := claims["target_audience"].(string); ok {
+				idToken, _ := jwt.Sign(jwt.RS256, testKey(t), nil, map[string]interface{}{"aud": audience, "exp": 4102444800})
+				fmt.Fprintf(w, `{"id_token":%q}`, idToken)
+				return
+			}
+			fmt.Fprintf(w, `{"access_token":"sa-token %s %v","token_type":"Bearer","expires_in":3599}`, claims["scope"], claims["sub"])
+
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"unsupported_grant_type"}`)
+		}
+	}))
+}
+
+func testKey(t *testing.T) *rsa.PrivateKey {
+	raw, err := os.ReadFile("../../jwt/testdata/rsa-pkcs8.pem")
+	assert.NoError(t, err)
+	key, err := jwt.ParsePrivateKey(raw)
+	assert.NoError(t, err)
+	return key.(*rsa.PrivateKey)
+}
+
+func TestProvider(t *testing.T) {
+	server := tokenStub(t, &testKey(t).PublicKey)
+	defer server.Close()
+
+	provider, err := Create("gcp", map[string]interface{}{
+		"token-endpoint": server.URL,
+		"credentials":    "./testdata/authorized-user.json",
+		"profiles": map[string]interface{}{
+			"sa": map[string]interface{}{
+				"key-file": "./testdata/service-account.json",
+			},
+			"delegated": map[string]interface{}{
+				"key-file": "./testdata/service-account.json",
+				"scopes":   []string{"https://www.googleapis.com/auth/admin.directory.user.readonly"},
+				"subject":  "admin@example.com",
+			},
+			"run": map[string]interface{}{
+				"key-file": "./testdata/service-account.json",
+				"audience": "https://service.run.app",
+			},
+			"external": map[string]interface{}{
+				"key-file": "./testdata/external-account.json",
+			},
+			"missing": map[string]interface{}{
+				"key-file": "./testdata/missing.json",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "gcp", provider.Name())
+	assert.Contains(t, provider.Files(), "./testdata/authorized-user.json")
+	assert.Contains(t, provider.Files(), "./testdata/service-account.json")
+
+	for name, expected := range map[string]string{
+		"sa":        "sa-token " + defaultScope + " <nil>",
+		"delegated": "sa-token https://www.googleapis.com/auth/admin.directory.user.readonly admin@example.com",
+		"$default":  "user-token",
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), profile.Metadata().Expires, time.Minute)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields["accessToken"])
+		assert.Equal(t, profile.Metadata().Expires.UTC().Format(time.RFC3339), fields["expiration"])
+	}
+
+	profile, err := provider.Get("run")
+	assert.NoError(t, err)
+	expires := time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.True(t, expires.Equal(profile.Metadata().Expires))
+
+	fields := map[string]string{}
+	assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+	assert.Equal(t, "2100-01-01T00:00:00Z", fields["expiration"])
+	assert.Empty(t, fields["accessToken"])
+	claims, err := jwt.Claims(fields["idToken"])
+	assert.NoError(t, err)
+	assert.Equal(t, "https://service.run.app", claims["aud"])
+
+	for _, name := range []string{"external", "missing", "notexist"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"token-endpoint": "not a url"},
+		{"timeout": "forever"},
+		{"profiles": map[string]interface{}{"x": map[string]interface{}{"scopes": []string{"scope"}}}},
+		{"scopes": "scope"},
+	} {
+		_, err := Create("gcp", config)
+		assert.Error(t, err, config)
+	}
+}
@@ -0,0 +1,186 @@
+// Package github is a provider that can be used by the providers package to
+// retrieve github tokens from the hosts config of the github cli or from
+// environmental variables.
+package github
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults used if not configured.
+const (
+	defaultHost    = "github.com"
+	defaultProfile = "$default"
+)
+
+// Environmental variables used if the host isn't in the hosts config, in order of precedence.
+var (
+	envTokens           = []string{"GH_TOKEN", "GITHUB_TOKEN"}
+	envEnterpriseTokens = []string{"GH_ENTERPRISE_TOKEN", "GITHUB_ENTERPRISE_TOKEN"}
+)
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Hosts string `mapstructure:"hosts"`
+	}{Hosts: defaultHosts()}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("github: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	return &Provider{name: name, hosts: data.Hosts}, nil
+}
+
+// defaultHosts returns hosts.yml in the config directory of the github cli.
+func defaultHosts() string {
+	if dir := os.Getenv("GH_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "hosts.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gh", "hosts.yml")
+	}
+	if runtime.GOOS == "windows" {
+		return filepath.Join(os.Getenv("AppData"), "GitHub CLI", "hosts.yml")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gh", "hosts.yml")
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name  string
+	hosts string
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve the token of host name. Profile $default is github.com.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	host := name
+	if name == defaultProfile {
+		host = defaultHost
+	}
+
+	hosts, err := readHosts(p.hosts)
+	if err != nil {
+		return nil, fmt.Errorf("github: couldn't read hosts for %q. %w", p.Name(), err)
+	}
+
+	fields := map[string]string{"host": host, "apiUrl": apiURL(host)}
+	source := p.hosts
+	switch cfg, ok := hosts[host]; {
+	case ok && cfg != nil && cfg.OAuthToken != "":
+		fields["token"] = cfg.OAuthToken
+		if cfg.User != "" {
+			fields["user"] = cfg.User
+		}
+
+	// The enterprise token variables are only used for the host in GH_HOST so they
+	// aren't sent to other hosts.
+	case host == defaultHost || host == os.Getenv("GH_HOST"):
+		vars := envTokens
+		if host != defaultHost {
+			vars = envEnterpriseTokens
+		}
+		for _, env := range vars {
+			if token := os.Getenv(env); token != "" {
+				fields["token"], source = token, env
+				break
+			}
+		}
+	}
+	if fields["token"] == "" {
+		return nil, fmt.Errorf("github: no token for host %q in %q", host, p.Name())
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("github: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Source: fmt.Sprintf("github: %s", source),
+			Kind:   types.KindStatic,
+		},
+	}, nil
+}
+
+// Files returns the hosts config file.
+func (p *Provider) Files() []string {
+	return []string{p.hosts}
+}
+
+// host is a host in the hosts config of the github cli.
+type host struct {
+	User       string `yaml:"user"`
+	OAuthToken string `yaml:"oauth_token"`
+}
+
+// readHosts will read the hosts config from file fn. A missing file is treated as empty.
+func readHosts(fn string) (map[string]*host, error) {
+	hosts := map[string]*host{}
+
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return hosts, nil
+		}
+		return nil, fmt.Errorf("couldn't read file %q. %w", fn, err)
+	}
+
+	if err := yaml.Unmarshal(raw, &hosts); err != nil {
+		return nil, fmt.Errorf("couldn't yaml unmarshal file %q. %w", fn, err)
+	}
+	return hosts, nil
+}
+
+// apiURL returns the api url of host.
+func apiURL(host string) string {
+	if host == defaultHost {
+		return "https://api.github.com"
+	}
+	return fmt.Sprintf("https://%s/api/v3", host)
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
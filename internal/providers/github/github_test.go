@@ -0,0 +1,112 @@
+package github
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	for _, env := range append(envTokens, envEnterpriseTokens...) {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+
+	provider, err := Create("github", map[string]interface{}{"hosts": "./testdata/hosts.yml"})
+	assert.NoError(t, err)
+	assert.Equal(t, "github", provider.Name())
+	assert.Equal(t, []string{"./testdata/hosts.yml"}, provider.Files())
+
+	for name, expected := range map[string]map[string]string{
+		"$default": {
+			"host": "github.com", "apiUrl": "https://api.github.com", "token": "gho_github", "user": "octocat",
+		},
+		"github.com": {
+			"host": "github.com", "apiUrl": "https://api.github.com", "token": "gho_github", "user": "octocat",
+		},
+		"github.example.com": {
+			"host": "github.example.com", "apiUrl": "https://github.example.com/api/v3", "token": "gho_enterprise", "user": "enterprise",
+		},
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindStatic, profile.Metadata().Kind)
+		assert.Equal(t, "github: ./testdata/hosts.yml", profile.Metadata().Source)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields, name)
+	}
+
+	// Tokens stored in the keyring aren't in the hosts config.
+	_, err = provider.Get("keyring.example.com")
+	assert.EqualError(t, err, `github: no token for host "keyring.example.com" in "github"`)
+
+	// Hosts without any settings are the same as missing hosts.
+	_, err = provider.Get("empty.example.com")
+	assert.EqualError(t, err, `github: no token for host "empty.example.com" in "github"`)
+}
+
+func TestEnvFallback(t *testing.T) {
+	for _, env := range append(envTokens, append(envEnterpriseTokens, "GH_HOST")...) {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	os.Setenv("GH_HOST", "keyring.example.com")
+	os.Setenv("GITHUB_TOKEN", "env-github")
+	os.Setenv("GH_ENTERPRISE_TOKEN", "env-enterprise")
+
+	provider, err := Create("github", map[string]interface{}{"hosts": "./testdata/missing.yml"})
+	assert.NoError(t, err)
+
+	for name, expected := range map[string]string{
+		"$default":            "env-github",
+		"keyring.example.com": "env-enterprise",
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields["token"], name)
+		assert.Empty(t, fields["user"])
+	}
+
+	// The enterprise token isn't sent to other hosts than GH_HOST.
+	_, err = provider.Get("other.example.com")
+	assert.EqualError(t, err, `github: no token for host "other.example.com" in "github"`)
+
+	// GH_TOKEN has precedence over GITHUB_TOKEN.
+	os.Setenv("GH_TOKEN", "env-gh")
+	profile, err := provider.Get("github.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "github: GH_TOKEN", profile.Metadata().Source)
+	assert.Contains(t, string(profile.Payload()), `"token":"env-gh"`)
+}
+
+func TestDefaultHosts(t *testing.T) {
+	defer os.Setenv("GH_CONFIG_DIR", os.Getenv("GH_CONFIG_DIR"))
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+
+	os.Setenv("GH_CONFIG_DIR", "/gh")
+	assert.Equal(t, "/gh/hosts.yml", defaultHosts())
+	os.Setenv("GH_CONFIG_DIR", "")
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, "/xdg/gh/hosts.yml", defaultHosts())
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Create("github", map[string]interface{}{"hosts": []string{"hosts.yml"}})
+	assert.Error(t, err)
+
+	provider, err := Create("github", map[string]interface{}{"hosts": "./testdata"})
+	assert.NoError(t, err)
+	_, err = provider.Get("github.com")
+	assert.Error(t, err)
+}
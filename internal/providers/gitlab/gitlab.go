@@ -0,0 +1,198 @@
+// Package gitlab is a provider that can be used by the providers package to
+// retrieve gitlab tokens from the config of the gitlab cli or from
+// environmental variables.
+package gitlab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults used if not configured.
+const (
+	defaultHost     = "gitlab.com"
+	defaultProtocol = "https"
+	defaultProfile  = "$default"
+)
+
+// envTokens are the environmental variables used if the host isn't in the config, in order of precedence.
+var envTokens = []string{"GITLAB_TOKEN", "GITLAB_ACCESS_TOKEN", "OAUTH_TOKEN"}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Config string `mapstructure:"config"`
+	}{Config: defaultConfig()}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("gitlab: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	return &Provider{name: name, config: data.Config}, nil
+}
+
+// defaultConfig returns config.yml in the config directory of the gitlab cli.
+func defaultConfig() string {
+	if dir := os.Getenv("GLAB_CONFIG_DIR"); dir != "" {
+		return filepath.Join(dir, "config.yml")
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "glab-cli", "config.yml")
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "glab-cli", "config.yml")
+}
+
+// envHost returns the host the token environmental variables are used for.
+func envHost() string {
+	if host := os.Getenv("GITLAB_HOST"); host != "" {
+		return host
+	}
+	return defaultHost
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name   string
+	config string
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve the token of host name. Profile $default is GITLAB_HOST or gitlab.com.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	hostName := name
+	if name == defaultProfile {
+		hostName = envHost()
+	}
+
+	cfg, err := readConfig(p.config)
+	if err != nil {
+		return nil, fmt.Errorf("gitlab: couldn't read config for %q. %w", p.Name(), err)
+	}
+
+	h, ok := cfg.Hosts[hostName]
+	if !ok || h == nil {
+		h = &host{}
+	}
+	fields := map[string]string{"host": hostName, "apiUrl": h.apiURL(hostName)}
+	source := p.config
+	switch {
+	case h.Token != "":
+		fields["token"] = h.Token
+		if h.User != "" {
+			fields["user"] = h.User
+		}
+
+	// The token variables are only used for one host so they aren't sent to other hosts.
+	case hostName == envHost():
+		for _, env := range envTokens {
+			if token := os.Getenv(env); token != "" {
+				fields["token"], source = token, env
+				break
+			}
+		}
+	}
+	if fields["token"] == "" {
+		return nil, fmt.Errorf("gitlab: no token for host %q in %q", hostName, p.Name())
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("gitlab: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Source: fmt.Sprintf("gitlab: %s", source),
+			Kind:   types.KindStatic,
+		},
+	}, nil
+}
+
+// Files returns the config file.
+func (p *Provider) Files() []string {
+	return []string{p.config}
+}
+
+// config is the config of the gitlab cli.
+type config struct {
+	Hosts map[string]*host `yaml:"hosts"`
+}
+
+// host is a host in the config of the gitlab cli.
+type host struct {
+	Token       string `yaml:"token"`
+	User        string `yaml:"user"`
+	APIHost     string `yaml:"api_host"`
+	APIProtocol string `yaml:"api_protocol"`
+}
+
+// apiURL returns the api url of host name.
+func (h *host) apiURL(name string) string {
+	apiHost, protocol := h.APIHost, h.APIProtocol
+	if apiHost == "" {
+		apiHost = name
+	}
+	if protocol == "" {
+		protocol = defaultProtocol
+	}
+	return fmt.Sprintf("%s://%s/api/v4", protocol, apiHost)
+}
+
+// readConfig will read the config from file fn. A missing file is treated as empty.
+func readConfig(fn string) (*config, error) {
+	cfg := &config{}
+
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("couldn't read file %q. %w", fn, err)
+	}
+
+	if err := yaml.Unmarshal(raw, cfg); err != nil {
+		return nil, fmt.Errorf("couldn't yaml unmarshal file %q. %w", fn, err)
+	}
+	return cfg, nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,109 @@
+package gitlab
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	for _, env := range append(envTokens, "GITLAB_HOST") {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+
+	provider, err := Create("gitlab", map[string]interface{}{"config": "./testdata/config.yml"})
+	assert.NoError(t, err)
+	assert.Equal(t, "gitlab", provider.Name())
+	assert.Equal(t, []string{"./testdata/config.yml"}, provider.Files())
+
+	for name, expected := range map[string]map[string]string{
+		"$default": {
+			"host": "gitlab.com", "apiUrl": "https://gitlab.com/api/v4", "token": "glpat-gitlab", "user": "tanuki",
+		},
+		"gitlab.com": {
+			"host": "gitlab.com", "apiUrl": "https://gitlab.com/api/v4", "token": "glpat-gitlab", "user": "tanuki",
+		},
+		"gitlab.example.com": {
+			"host": "gitlab.example.com", "apiUrl": "http://api.gitlab.example.com/api/v4", "token": "glpat-self-managed",
+		},
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindStatic, profile.Metadata().Kind)
+		assert.Equal(t, "gitlab: ./testdata/config.yml", profile.Metadata().Source)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields, name)
+	}
+
+	_, err = provider.Get("keyring.example.com")
+	assert.EqualError(t, err, `gitlab: no token for host "keyring.example.com" in "gitlab"`)
+
+	// Hosts without any settings are the same as missing hosts.
+	_, err = provider.Get("empty.example.com")
+	assert.EqualError(t, err, `gitlab: no token for host "empty.example.com" in "gitlab"`)
+}
+
+func TestEnvFallback(t *testing.T) {
+	for _, env := range append(envTokens, "GITLAB_HOST") {
+		defer os.Setenv(env, os.Getenv(env))
+		os.Unsetenv(env)
+	}
+	os.Setenv("GITLAB_ACCESS_TOKEN", "env-token")
+
+	provider, err := Create("gitlab", map[string]interface{}{"config": "./testdata/config.yml"})
+	assert.NoError(t, err)
+
+	// The variables are only used for GITLAB_HOST.
+	_, err = provider.Get("keyring.example.com")
+	assert.Error(t, err)
+
+	os.Setenv("GITLAB_HOST", "keyring.example.com")
+	for _, name := range []string{"$default", "keyring.example.com"} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, "gitlab: GITLAB_ACCESS_TOKEN", profile.Metadata().Source)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, map[string]string{
+			"host": "keyring.example.com", "apiUrl": "https://keyring.example.com/api/v4", "token": "env-token",
+		}, fields, name)
+	}
+
+	// Tokens in the config has precedence.
+	os.Setenv("GITLAB_HOST", "gitlab.com")
+	profile, err := provider.Get("$default")
+	assert.NoError(t, err)
+	assert.Contains(t, string(profile.Payload()), `"token":"glpat-gitlab"`)
+}
+
+func TestDefaultConfig(t *testing.T) {
+	defer os.Setenv("GLAB_CONFIG_DIR", os.Getenv("GLAB_CONFIG_DIR"))
+	defer os.Setenv("XDG_CONFIG_HOME", os.Getenv("XDG_CONFIG_HOME"))
+
+	os.Setenv("GLAB_CONFIG_DIR", "/glab")
+	assert.Equal(t, "/glab/config.yml", defaultConfig())
+	os.Setenv("GLAB_CONFIG_DIR", "")
+	os.Setenv("XDG_CONFIG_HOME", "/xdg")
+	assert.Equal(t, "/xdg/glab-cli/config.yml", defaultConfig())
+}
+
+func TestErrors(t *testing.T) {
+	_, err := Create("gitlab", map[string]interface{}{"config": []string{"config.yml"}})
+	assert.Error(t, err)
+
+	provider, err := Create("gitlab", map[string]interface{}{"config": "./testdata"})
+	assert.NoError(t, err)
+	_, err = provider.Get("gitlab.com")
+	assert.Error(t, err)
+}
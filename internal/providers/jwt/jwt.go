@@ -0,0 +1,311 @@
+// Package jwt is a provider that can be used by the providers package to
+// mint json web tokens signed with a rsa, ecdsa or ed25519 key from disk,
+// for services that accepts self-signed tokens.
+package jwt
+
+import (
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	jwtlib "github.com/nuttmeister/pm-creds/internal/jwt"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Defaults used if not configured.
+const (
+	defaultLifetime = 5 * time.Minute
+	defaultProfile  = "$default"
+)
+
+// registered are the claims that are always set by the provider.
+var registered = []string{"iat", "nbf", "exp", "jti"}
+
+// profile is the configuration of a single profile. Profiles inherit the provider configuration.
+type profile struct {
+	KeyFile   string                 `mapstructure:"key-file"`
+	Algorithm string                 `mapstructure:"algorithm"`
+	KeyID     string                 `mapstructure:"key-id"`
+	Issuer    string                 `mapstructure:"issuer"`
+	Subject   string                 `mapstructure:"subject"`
+	Audience  []string               `mapstructure:"audience"`
+	Lifetime  string                 `mapstructure:"lifetime"`
+	Claims    map[string]interface{} `mapstructure:"claims"`
+
+	lifetime time.Duration
+}
+
+// inherit will set all fields not set in p from parent.
+func (p *profile) inherit(parent *profile) {
+	if p.KeyFile == "" {
+		p.KeyFile = parent.KeyFile
+	}
+	if p.Algorithm == "" {
+		p.Algorithm = parent.Algorithm
+	}
+	if p.KeyID == "" {
+		p.KeyID = parent.KeyID
+	}
+	if p.Issuer == "" {
+		p.Issuer = parent.Issuer
+	}
+	if p.Subject == "" {
+		p.Subject = parent.Subject
+	}
+	if len(p.Audience) == 0 {
+		p.Audience = parent.Audience
+	}
+	if p.Lifetime == "" {
+		p.Lifetime = parent.Lifetime
+	}
+	claims := map[string]interface{}{}
+	for key, value := range parent.Claims {
+		claims[key] = value
+	}
+	for key, value := range p.Claims {
+		claims[key] = value
+	}
+	p.Claims = claims
+}
+
+// validate returns error if p is missing required fields.
+func (p *profile) validate() error {
+	if p.KeyFile == "" {
+		return fmt.Errorf("no %s set", "key-file")
+	}
+
+	p.lifetime = defaultLifetime
+	if p.Lifetime != "" {
+		lifetime, err := time.ParseDuration(p.Lifetime)
+		if err != nil {
+			return fmt.Errorf("couldn't parse %s. %w", "lifetime", err)
+		}
+		if lifetime <= 0 {
+			return fmt.Errorf("%s must be positive", "lifetime")
+		}
+		p.lifetime = lifetime
+	}
+
+	for _, claim := range registered {
+		if _, ok := p.Claims[claim]; ok {
+			return fmt.Errorf("claim %s is set by the provider and can't be configured", claim)
+		}
+	}
+
+	if p.Algorithm != "" {
+		if err := jwtlib.Check(p.Algorithm, nil); err != nil {
+			return fmt.Errorf("invalid %s %q", "algorithm", p.Algorithm)
+		}
+	}
+	// The key is read again for every token, so it's only checked against the algorithm
+	// if it can be read now.
+	if key, err := p.key(); err == nil {
+		if _, err := p.algorithm(key); err != nil {
+			return fmt.Errorf("couldn't use key file %q. %w", p.KeyFile, err)
+		}
+	}
+	return nil
+}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	defaults := &profile{}
+	if err := mapstructure.Decode(raw, defaults); err != nil {
+		return nil, fmt.Errorf("jwt: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	data := &struct {
+		Profiles map[string]*profile `mapstructure:"profiles"`
+	}{}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("jwt: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	profiles := map[string]*profile{}
+	if defaults.KeyFile != "" {
+		profiles[defaultProfile] = &profile{}
+	}
+	for profileName, cfg := range data.Profiles {
+		profiles[profileName] = cfg
+	}
+	for profileName, cfg := range profiles {
+		cfg.inherit(defaults)
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("jwt: profile %q for %q is invalid. %w", profileName, name, err)
+		}
+	}
+
+	return &Provider{
+		name:     name,
+		profiles: profiles,
+	}, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name     string
+	profiles map[string]*profile
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will mint a new token for profile name. Profile $default uses the settings of the provider.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	cfg, ok := p.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("jwt: no profile %q in %q", name, p.Name())
+	}
+
+	token, expires, err := cfg.sign(time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("jwt: couldn't sign token for %q from %q. %w", name, p.Name(), err)
+	}
+
+	payload, err := json.Marshal(map[string]interface{}{
+		"token":      token,
+		"expiration": expires.UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("jwt: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Expires: expires,
+			Source:  fmt.Sprintf("jwt: %s", cfg.KeyFile),
+			Kind:    types.KindSession,
+		},
+	}, nil
+}
+
+// Files returns the key files used.
+func (p *Provider) Files() []string {
+	files := []string{}
+	for _, cfg := range p.profiles {
+		files = append(files, cfg.KeyFile)
+	}
+	return files
+}
+
+// key will read and return the key of p.
+func (p *profile) key() (crypto.Signer, error) {
+	raw, err := os.ReadFile(p.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read key file %q. %w", p.KeyFile, err)
+	}
+	return jwtlib.ParsePrivateKey(raw)
+}
+
+// algorithm returns the algorithm of p, or the default algorithm of key if it's not set.
+// Returns error if the algorithm can't be used with key.
+func (p *profile) algorithm(key crypto.Signer) (string, error) {
+	alg := p.Algorithm
+	if alg == "" {
+		var err error
+		if alg, err = jwtlib.Algorithm(key); err != nil {
+			return "", err
+		}
+	}
+	if err := jwtlib.Check(alg, key); err != nil {
+		return "", err
+	}
+	return alg, nil
+}
+
+// sign will read the key of p and return a token issued at now and when it expires.
+// The key is read every time so that rotated keys are used right away.
+func (p *profile) sign(now time.Time) (string, time.Time, error) {
+	key, err := p.key()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	alg, err := p.algorithm(key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	id, err := jti()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	expires := now.Add(p.lifetime)
+	claims := map[string]interface{}{}
+	for key, value := range p.Claims {
+		claims[key] = value
+	}
+	if p.Issuer != "" {
+		claims["iss"] = p.Issuer
+	}
+	if p.Subject != "" {
+		claims["sub"] = p.Subject
+	}
+	// A single audience is set as a string, see rfc 7519 section 4.1.3.
+	switch len(p.Audience) {
+	case 0:
+	case 1:
+		claims["aud"] = p.Audience[0]
+	default:
+		claims["aud"] = p.Audience
+	}
+	claims["iat"] = now.Unix()
+	claims["nbf"] = now.Unix()
+	claims["exp"] = expires.Unix()
+	claims["jti"] = id
+
+	header := map[string]interface{}{}
+	if p.KeyID != "" {
+		header["kid"] = p.KeyID
+	}
+
+	token, err := jwtlib.Sign(alg, key, header, claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	// Claims are only precise to the second.
+	return token, time.Unix(expires.Unix(), 0), nil
+}
+
+// jti returns a random token id.
+func jti() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("couldn't generate %s. %w", "jti", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,137 @@
+package jwt
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	jwtlib "github.com/nuttmeister/pm-creds/internal/jwt"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProvider(t *testing.T) {
+	provider, err := Create("jwt", map[string]interface{}{
+		"key-file": "../../jwt/testdata/rsa-pkcs8.pem",
+		"issuer":   "pm-creds",
+		"audience": []string{"https://api.example.com"},
+		"claims":   map[string]interface{}{"team": "payments"},
+		"profiles": map[string]interface{}{
+			"orders": map[string]interface{}{
+				"key-file":  "../../jwt/testdata/ec-p256.pem",
+				"key-id":    "orders-1",
+				"subject":   "orders",
+				"audience":  []string{"https://orders.example.com", "https://billing.example.com"},
+				"lifetime":  "1h",
+				"claims":    map[string]interface{}{"scope": "orders:read", "admin": false},
+				"algorithm": jwtlib.ES256,
+			},
+			"edge": map[string]interface{}{
+				"key-file": "../../jwt/testdata/ed25519.pem",
+			},
+			"missing": map[string]interface{}{
+				"key-file": "./testdata/missing.pem",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "jwt", provider.Name())
+	assert.Contains(t, provider.Files(), "../../jwt/testdata/rsa-pkcs8.pem")
+	assert.Contains(t, provider.Files(), "../../jwt/testdata/ec-p256.pem")
+
+	for name, expected := range map[string]struct {
+		header   map[string]interface{}
+		claims   map[string]interface{}
+		lifetime time.Duration
+	}{
+		"$default": {
+			header: map[string]interface{}{"alg": jwtlib.RS256, "typ": "JWT"},
+			claims: map[string]interface{}{
+				"iss": "pm-creds", "aud": "https://api.example.com", "team": "payments",
+			},
+			lifetime: defaultLifetime,
+		},
+		"orders": {
+			header: map[string]interface{}{"alg": jwtlib.ES256, "typ": "JWT", "kid": "orders-1"},
+			claims: map[string]interface{}{
+				"iss": "pm-creds", "sub": "orders", "team": "payments", "scope": "orders:read", "admin": false,
+				"aud": []interface{}{"https://orders.example.com", "https://billing.example.com"},
+			},
+			lifetime: time.Hour,
+		},
+		"edge": {
+			header: map[string]interface{}{"alg": jwtlib.EdDSA, "typ": "JWT"},
+			claims: map[string]interface{}{
+				"iss": "pm-creds", "aud": "https://api.example.com", "team": "payments",
+			},
+			lifetime: defaultLifetime,
+		},
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+		assert.WithinDuration(t, time.Now().Add(expected.lifetime), profile.Metadata().Expires, time.Minute, name)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, profile.Metadata().Expires.UTC().Format(time.RFC3339), fields["expiration"])
+
+		rawHeader, err := base64.RawURLEncoding.DecodeString(strings.Split(fields["token"], ".")[0])
+		assert.NoError(t, err)
+		header := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal(rawHeader, &header))
+		assert.Equal(t, expected.header, header, name)
+
+		claims, err := jwtlib.Claims(fields["token"])
+		assert.NoError(t, err)
+		assert.Equal(t, float64(profile.Metadata().Expires.Unix()), claims["exp"], name)
+		assert.Equal(t, claims["iat"], claims["nbf"], name)
+		assert.Len(t, claims["jti"], 32)
+		for _, claim := range registered {
+			delete(claims, claim)
+		}
+		assert.Equal(t, expected.claims, claims, name)
+	}
+
+	// Every token is unique.
+	first, err := provider.Get("$default")
+	assert.NoError(t, err)
+	second, err := provider.Get("$default")
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.Payload(), second.Payload())
+
+	for _, name := range []string{"missing", "notexist"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestCreateErrors(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"profiles": map[string]interface{}{"x": map[string]interface{}{"issuer": "pm-creds"}}},
+		{"key-file": "key.pem", "lifetime": "forever"},
+		{"key-file": "key.pem", "lifetime": "-5m"},
+		{"key-file": "key.pem", "claims": map[string]interface{}{"exp": 0}},
+		{"key-file": "key.pem", "audience": "https://api.example.com"},
+		{"key-file": "key.pem", "algorithm": "HS256"},
+		{"key-file": "key.pem", "algorithm": "es256"},
+		{"key-file": "../../jwt/testdata/rsa-pkcs8.pem", "algorithm": jwtlib.ES256},
+		{"key-file": "../../jwt/testdata/ec-p256.pem", "algorithm": jwtlib.ES384},
+		{"key-file": "../../jwt/testdata/ed25519.pem", "algorithm": jwtlib.PS256},
+	} {
+		_, err := Create("jwt", config)
+		assert.Error(t, err, config)
+	}
+
+	// No profiles without a key-file.
+	provider, err := Create("jwt", map[string]interface{}{})
+	assert.NoError(t, err)
+	_, err = provider.Get(defaultProfile)
+	assert.Error(t, err)
+}
@@ -0,0 +1,397 @@
+// Package kubeconfig is a provider that can be used by the providers package to
+// retrieve kubernetes api credentials from kubeconfig files, including the
+// output of exec credential plugins.
+package kubeconfig
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Defaults used if not configured.
+const (
+	defaultExecTimeout = 30 * time.Second
+	defaultProfile     = "$default"
+)
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Kubeconfigs []string `mapstructure:"kubeconfigs"`
+		ExecTimeout string   `mapstructure:"exec-timeout"`
+	}{}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("kubeconfig: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	execTimeout := defaultExecTimeout
+	if data.ExecTimeout != "" {
+		var err error
+		if execTimeout, err = time.ParseDuration(data.ExecTimeout); err != nil {
+			return nil, fmt.Errorf("kubeconfig: couldn't parse %s for %q. %w", "exec-timeout", name, err)
+		}
+	}
+
+	return &Provider{
+		name:        name,
+		kubeconfigs: data.Kubeconfigs,
+		execTimeout: execTimeout,
+	}, nil
+}
+
+// defaultKubeconfigs returns the files in KUBECONFIG or ~/.kube/config.
+func defaultKubeconfigs() []string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		files := []string{}
+		for _, fn := range filepath.SplitList(env) {
+			if fn != "" {
+				files = append(files, fn)
+			}
+		}
+		return files
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	return []string{filepath.Join(home, ".kube", "config")}
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	kubeconfigs []string
+	execTimeout time.Duration
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve the credentials of context name. Profile $default uses the current-context.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	merged, err := load(p.Files())
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: couldn't load kubeconfigs for %q. %w", p.Name(), err)
+	}
+
+	contextName := name
+	if name == defaultProfile {
+		if merged.currentContext == "" {
+			return nil, fmt.Errorf("kubeconfig: no current-context set in %q", p.Name())
+		}
+		contextName = merged.currentContext
+	}
+
+	ctx, ok := merged.contexts[contextName]
+	if !ok {
+		return nil, fmt.Errorf("kubeconfig: no context %q in %q", contextName, p.Name())
+	}
+
+	creds, err := p.credentials(merged, ctx)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: couldn't get credentials for %q from %q. %w", name, p.Name(), err)
+	}
+
+	fields := map[string]interface{}{
+		"server": creds.server,
+	}
+	for key, value := range map[string]string{
+		"certificateAuthority": creds.certificateAuthority,
+		"namespace":            creds.namespace,
+		"token":                creds.token,
+		"clientCertificate":    creds.clientCertificate,
+		"clientKey":            creds.clientKey,
+		"username":             creds.username,
+		"password":             creds.password,
+	} {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	if creds.insecure {
+		fields["insecureSkipTlsVerify"] = true
+	}
+
+	metadata := types.Metadata{
+		Source: fmt.Sprintf("kubeconfig: %s (%s)", ctx.file, contextName),
+		Kind:   types.KindStatic,
+	}
+	if !creds.expires.IsZero() {
+		metadata.Expires = creds.expires
+		metadata.Kind = types.KindSession
+		fields["expiration"] = creds.expires.UTC().Format(time.RFC3339)
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("kubeconfig: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{name: name, payload: payload, metadata: metadata}, nil
+}
+
+// Files returns the kubeconfig files that contexts are read from.
+func (p *Provider) Files() []string {
+	if p.kubeconfigs == nil {
+		return defaultKubeconfigs()
+	}
+	return p.kubeconfigs
+}
+
+// credentials is the resolved credentials of a context.
+type credentials struct {
+	server               string
+	certificateAuthority string
+	insecure             bool
+	namespace            string
+
+	token             string
+	clientCertificate string
+	clientKey         string
+	username          string
+	password          string
+	expires           time.Time
+}
+
+// credentials will resolve the cluster and user of ctx in merged. Files referenced by
+// the kubeconfig are read and the exec plugin is run if the user has one.
+func (p *Provider) credentials(merged *merged, ctx *namedContext) (*credentials, error) {
+	cluster, ok := merged.clusters[ctx.Cluster]
+	if !ok {
+		return nil, fmt.Errorf("no cluster %q in kubeconfigs", ctx.Cluster)
+	}
+	if cluster.Server == "" {
+		return nil, fmt.Errorf("no server set for cluster %q", ctx.Cluster)
+	}
+
+	creds := &credentials{
+		server:    cluster.Server,
+		insecure:  cluster.InsecureSkipTLSVerify,
+		namespace: ctx.Namespace,
+	}
+
+	var err error
+	if creds.certificateAuthority, err = readData(cluster.CertificateAuthorityData, cluster.CertificateAuthority, cluster.dir); err != nil {
+		return nil, fmt.Errorf("couldn't read certificate authority of cluster %q. %w", ctx.Cluster, err)
+	}
+
+	if ctx.User == "" {
+		return creds, nil
+	}
+	user, ok := merged.users[ctx.User]
+	if !ok {
+		return nil, fmt.Errorf("no user %q in kubeconfigs", ctx.User)
+	}
+
+	if user.Exec != nil {
+		status, err := p.runPlugin(user.Exec, creds, user.dir)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't run exec plugin of user %q. %w", ctx.User, err)
+		}
+		creds.token = status.Token
+		creds.clientCertificate = status.ClientCertificateData
+		creds.clientKey = status.ClientKeyData
+		if status.ExpirationTimestamp != "" {
+			if creds.expires, err = time.Parse(time.RFC3339, status.ExpirationTimestamp); err != nil {
+				return nil, fmt.Errorf("couldn't parse expiration of exec plugin of user %q. %w", ctx.User, err)
+			}
+		}
+		return creds, nil
+	}
+
+	switch {
+	case user.Token != "":
+		creds.token = user.Token
+	case user.TokenFile != "":
+		raw, err := os.ReadFile(resolve(user.TokenFile, user.dir))
+		if err != nil {
+			return nil, fmt.Errorf("couldn't read token file of user %q. %w", ctx.User, err)
+		}
+		creds.token = strings.TrimSpace(string(raw))
+	}
+
+	if creds.clientCertificate, err = readData(user.ClientCertificateData, user.ClientCertificate, user.dir); err != nil {
+		return nil, fmt.Errorf("couldn't read client certificate of user %q. %w", ctx.User, err)
+	}
+	if creds.clientKey, err = readData(user.ClientKeyData, user.ClientKey, user.dir); err != nil {
+		return nil, fmt.Errorf("couldn't read client key of user %q. %w", ctx.User, err)
+	}
+	creds.username, creds.password = user.Username, user.Password
+
+	return creds, nil
+}
+
+// readData returns the base64 decoded data or the content of file fn relative to dir.
+func readData(data string, fn string, dir string) (string, error) {
+	switch {
+	case data != "":
+		raw, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return "", fmt.Errorf("couldn't base64 decode data. %w", err)
+		}
+		return string(raw), nil
+
+	case fn != "":
+		raw, err := os.ReadFile(resolve(fn, dir))
+		if err != nil {
+			return "", fmt.Errorf("couldn't read file %q. %w", fn, err)
+		}
+		return string(raw), nil
+	}
+	return "", nil
+}
+
+// resolve returns fn relative to dir unless it's absolute, the same way as kubectl.
+func resolve(fn string, dir string) string {
+	if fn == "" || filepath.IsAbs(fn) {
+		return fn
+	}
+	return filepath.Join(dir, fn)
+}
+
+// kubeconfig is the content of a kubeconfig file.
+type kubeconfig struct {
+	CurrentContext string `yaml:"current-context"`
+	Clusters       []struct {
+		Name    string   `yaml:"name"`
+		Cluster *cluster `yaml:"cluster"`
+	} `yaml:"clusters"`
+	Users []struct {
+		Name string `yaml:"name"`
+		User *user  `yaml:"user"`
+	} `yaml:"users"`
+	Contexts []struct {
+		Name    string        `yaml:"name"`
+		Context *namedContext `yaml:"context"`
+	} `yaml:"contexts"`
+}
+
+// cluster is a cluster in a kubeconfig.
+type cluster struct {
+	Server                   string `yaml:"server"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+
+	dir string
+}
+
+// user is a user in a kubeconfig.
+type user struct {
+	Token                 string      `yaml:"token"`
+	TokenFile             string      `yaml:"tokenFile"`
+	ClientCertificate     string      `yaml:"client-certificate"`
+	ClientCertificateData string      `yaml:"client-certificate-data"`
+	ClientKey             string      `yaml:"client-key"`
+	ClientKeyData         string      `yaml:"client-key-data"`
+	Username              string      `yaml:"username"`
+	Password              string      `yaml:"password"`
+	Exec                  *execConfig `yaml:"exec"`
+
+	dir string
+}
+
+// namedContext is a context in a kubeconfig.
+type namedContext struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace"`
+
+	file string
+}
+
+// merged is the clusters, users and contexts of all kubeconfig files.
+type merged struct {
+	currentContext string
+	clusters       map[string]*cluster
+	users          map[string]*user
+	contexts       map[string]*namedContext
+}
+
+// load will read and merge the kubeconfig files. The first file to set a value wins,
+// the same way as kubectl. Files that doesn't exist are ignored.
+func load(files []string) (*merged, error) {
+	m := &merged{
+		clusters: map[string]*cluster{},
+		users:    map[string]*user{},
+		contexts: map[string]*namedContext{},
+	}
+
+	for _, fn := range files {
+		raw, err := os.ReadFile(fn)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("couldn't read file %q. %w", fn, err)
+		}
+
+		cfg := &kubeconfig{}
+		if err := yaml.Unmarshal(raw, cfg); err != nil {
+			return nil, fmt.Errorf("couldn't yaml unmarshal file %q. %w", fn, err)
+		}
+		dir := filepath.Dir(fn)
+
+		if m.currentContext == "" {
+			m.currentContext = cfg.CurrentContext
+		}
+		for _, c := range cfg.Clusters {
+			if _, ok := m.clusters[c.Name]; !ok && c.Cluster != nil {
+				c.Cluster.dir = dir
+				m.clusters[c.Name] = c.Cluster
+			}
+		}
+		for _, u := range cfg.Users {
+			if _, ok := m.users[u.Name]; !ok && u.User != nil {
+				u.User.dir = dir
+				m.users[u.Name] = u.User
+			}
+		}
+		for _, c := range cfg.Contexts {
+			if _, ok := m.contexts[c.Name]; !ok && c.Context != nil {
+				c.Context.file = fn
+				m.contexts[c.Name] = c.Context
+			}
+		}
+	}
+
+	return m, nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,109 @@
+package kubeconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// execConfig is the exec credential plugin of a user.
+type execConfig struct {
+	APIVersion string   `yaml:"apiVersion"`
+	Command    string   `yaml:"command"`
+	Args       []string `yaml:"args"`
+	Env        []struct {
+		Name  string `yaml:"name"`
+		Value string `yaml:"value"`
+	} `yaml:"env"`
+	ProvideClusterInfo bool   `yaml:"provideClusterInfo"`
+	InteractiveMode    string `yaml:"interactiveMode"`
+}
+
+// execCredential is the input and output of exec credential plugins.
+type execCredential struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Cluster     *execCluster `json:"cluster,omitempty"`
+		Interactive bool         `json:"interactive"`
+	} `json:"spec"`
+	Status *execStatus `json:"status,omitempty"`
+}
+
+// execCluster is the cluster information given to plugins with provideClusterInfo.
+type execCluster struct {
+	Server                   string `json:"server"`
+	CertificateAuthorityData []byte `json:"certificate-authority-data,omitempty"`
+	InsecureSkipTLSVerify    bool   `json:"insecure-skip-tls-verify,omitempty"`
+}
+
+// execStatus is the credentials returned by plugins.
+type execStatus struct {
+	ExpirationTimestamp   string `json:"expirationTimestamp"`
+	Token                 string `json:"token"`
+	ClientCertificateData string `json:"clientCertificateData"`
+	ClientKeyData         string `json:"clientKeyData"`
+}
+
+// runPlugin will run the exec plugin cfg for the cluster in creds and return the credentials
+// it wrote to stdout. Relative commands are resolved from dir, the directory of the kubeconfig.
+func (p *Provider) runPlugin(cfg *execConfig, creds *credentials, dir string) (*execStatus, error) {
+	if cfg.Command == "" {
+		return nil, fmt.Errorf("no command set")
+	}
+	if cfg.InteractiveMode == "Always" {
+		return nil, fmt.Errorf("plugins with interactiveMode Always isn't supported")
+	}
+
+	// Only commands with a path are relative to the kubeconfig, others are looked up in PATH.
+	command := cfg.Command
+	if strings.ContainsRune(command, '/') || strings.ContainsRune(command, filepath.Separator) {
+		command = resolve(command, dir)
+	}
+
+	info := &execCredential{APIVersion: cfg.APIVersion, Kind: "ExecCredential"}
+	if cfg.ProvideClusterInfo {
+		info.Spec.Cluster = &execCluster{
+			Server:                   creds.server,
+			CertificateAuthorityData: []byte(creds.certificateAuthority),
+			InsecureSkipTLSVerify:    creds.insecure,
+		}
+	}
+	rawInfo, err := json.Marshal(info)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't json marshal exec info. %w", err)
+	}
+
+	cmd := &process.Command{
+		Args:    append([]string{command}, cfg.Args...),
+		Env:     []string{fmt.Sprintf("KUBERNETES_EXEC_INFO=%s", rawInfo)},
+		Timeout: p.execTimeout,
+	}
+	for _, env := range cfg.Env {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", env.Name, env.Value))
+	}
+
+	out, err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	cred := &execCredential{}
+	if err := json.Unmarshal(out, cred); err != nil {
+		return nil, fmt.Errorf("couldn't json unmarshal output of %q. %w", cfg.Command, err)
+	}
+	if cred.Kind != "ExecCredential" {
+		return nil, fmt.Errorf("output of %q has kind %q, expected %q", cfg.Command, cred.Kind, "ExecCredential")
+	}
+	if cfg.APIVersion != "" && cred.APIVersion != cfg.APIVersion {
+		return nil, fmt.Errorf("output of %q has apiVersion %q, expected %q", cfg.Command, cred.APIVersion, cfg.APIVersion)
+	}
+	if cred.Status == nil || (cred.Status.Token == "" && cred.Status.ClientCertificateData == "") {
+		return nil, fmt.Errorf("output of %q has no token or client certificate", cfg.Command)
+	}
+
+	return cred.Status, nil
+}
@@ -0,0 +1,18 @@
+#!/bin/sh
+# Stand-in for an exec credential plugin, echoes the cluster it was given as the certificate.
+if [ "$1" = "fail" ]; then
+    echo "not logged in" >&2
+    exit 1
+fi
+
+server=$(echo "$KUBERNETES_EXEC_INFO" | sed 's/.*"server":"\([^"]*\)".*/\1/')
+cat <<JSON
+{
+  "apiVersion": "client.authentication.k8s.io/v1beta1",
+  "kind": "ExecCredential",
+  "status": {
+    "token": "$PLUGIN_TOKEN $server",
+    "expirationTimestamp": "2100-01-01T00:00:00Z"
+  }
+}
+JSON
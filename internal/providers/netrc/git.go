@@ -0,0 +1,58 @@
+package netrc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// gitCommand is the command used to query the git credential helpers.
+var gitCommand = []string{"git", "credential", "fill"}
+
+// fromGit returns the credentials of host from the git credential helpers using
+// the git credential protocol. Git is never allowed to prompt in the terminal.
+// Hosts with line breaks or nul characters are rejected since they would add
+// attributes to the request.
+func (p *Provider) fromGit(host string) (*credentials, error) {
+	if strings.ContainsAny(host, "\n\r\x00") {
+		return nil, fmt.Errorf("invalid host %q for git credential", host)
+	}
+
+	cmd := &process.Command{
+		Args:    gitCommand,
+		Env:     []string{"GIT_TERMINAL_PROMPT=0", "GCM_INTERACTIVE=never"},
+		Stdin:   []byte(fmt.Sprintf("protocol=%s\nhost=%s\n\n", p.gitProtocol, host)),
+		Timeout: p.gitTimeout,
+	}
+
+	out, err := cmd.Run()
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &credentials{source: "git credential"}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		kv := strings.SplitN(scanner.Text(), "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "username":
+			creds.username = kv[1]
+		case "password":
+			creds.password = kv[1]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("couldn't read output of git credential. %w", err)
+	}
+
+	if creds.password == "" {
+		return nil, fmt.Errorf("no password returned by git credential for %q", host)
+	}
+	return creds, nil
+}
@@ -0,0 +1,274 @@
+// Package netrc is a provider that can be used by the providers package to
+// retrieve http basic auth credentials from netrc files and git credential
+// helpers.
+package netrc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Defaults used if not configured.
+const (
+	defaultGitProtocol = "https"
+	defaultGitTimeout  = 30 * time.Second
+	defaultProfile     = "$default"
+)
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Netrcs      []string `mapstructure:"netrcs"`
+		Git         bool     `mapstructure:"git"`
+		GitProtocol string   `mapstructure:"git-protocol"`
+		GitTimeout  string   `mapstructure:"git-timeout"`
+	}{GitProtocol: defaultGitProtocol}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("netrc: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	gitTimeout := defaultGitTimeout
+	if data.GitTimeout != "" {
+		var err error
+		if gitTimeout, err = time.ParseDuration(data.GitTimeout); err != nil {
+			return nil, fmt.Errorf("netrc: couldn't parse %s for %q. %w", "git-timeout", name, err)
+		}
+	}
+
+	return &Provider{
+		name:        name,
+		netrcs:      data.Netrcs,
+		git:         data.Git,
+		gitProtocol: data.GitProtocol,
+		gitTimeout:  gitTimeout,
+	}, nil
+}
+
+// defaultNetrcs returns the file in NETRC or ~/.netrc (~/_netrc on windows).
+func defaultNetrcs() []string {
+	if fn := os.Getenv("NETRC"); fn != "" {
+		return []string{fn}
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	if runtime.GOOS == "windows" {
+		return []string{filepath.Join(home, "_netrc")}
+	}
+	return []string{filepath.Join(home, ".netrc")}
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	netrcs      []string
+	git         bool
+	gitProtocol string
+	gitTimeout  time.Duration
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve the credentials of machine name. The netrc files are searched first and
+// then the git credential helper if enabled. Profile $default uses the default entry of the netrc files.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	// The default entry is only returned as $default, never for a machine without name.
+	if name == "" {
+		return nil, fmt.Errorf("netrc: no machine %q in %q", name, p.Name())
+	}
+
+	creds, err := p.fromNetrcs(name)
+	if err != nil {
+		return nil, fmt.Errorf("netrc: couldn't read credentials for %q from %q. %w", name, p.Name(), err)
+	}
+
+	if creds == nil && p.git && name != defaultProfile {
+		if creds, err = p.fromGit(name); err != nil {
+			return nil, fmt.Errorf("netrc: couldn't get credentials for %q from %q. %w", name, p.Name(), err)
+		}
+	}
+	if creds == nil {
+		return nil, fmt.Errorf("netrc: no machine %q in %q", name, p.Name())
+	}
+
+	payload, err := json.Marshal(map[string]string{
+		"username":      creds.username,
+		"password":      creds.password,
+		"authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte(creds.username+":"+creds.password)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("netrc: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Source: fmt.Sprintf("netrc: %s", creds.source),
+			Kind:   types.KindStatic,
+		},
+	}, nil
+}
+
+// Files returns the netrc files that machines are read from.
+func (p *Provider) Files() []string {
+	if p.netrcs == nil {
+		return defaultNetrcs()
+	}
+	return p.netrcs
+}
+
+// credentials is the credentials of a machine.
+type credentials struct {
+	username string
+	password string
+	source   string
+}
+
+// fromNetrcs returns the credentials of machine name from the first netrc file that has it.
+// Returns nil if no file has the machine. Files that doesn't exist are ignored.
+func (p *Provider) fromNetrcs(name string) (*credentials, error) {
+	for _, fn := range p.Files() {
+		raw, err := os.ReadFile(fn)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return nil, fmt.Errorf("couldn't read file %q. %w", fn, err)
+		}
+
+		machines, err := parse(raw)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse file %q. %w", fn, err)
+		}
+
+		machine := name
+		if name == defaultProfile {
+			machine = ""
+		}
+		if creds, ok := machines[machine]; ok {
+			creds.source = fn
+			return creds, nil
+		}
+	}
+	return nil, nil
+}
+
+// parse will parse the machines of a netrc file. The default entry is returned as machine "".
+// The first entry of a machine wins and macros are skipped.
+func parse(raw []byte) (map[string]*credentials, error) {
+	machines := map[string]*credentials{}
+
+	// Comments are removed and macro definitions are skipped until the next empty line.
+	tokens := []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(raw))
+	macro := false
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case macro && line == "":
+			macro = false
+			continue
+		case macro, strings.HasPrefix(line, "#"):
+			continue
+		}
+
+		fields := strings.Fields(line)
+		for i, field := range fields {
+			if field == "macdef" {
+				fields, macro = fields[:i], true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	var current *credentials
+	var machine string
+	add := func() {
+		if _, ok := machines[machine]; current != nil && !ok {
+			machines[machine] = current
+		}
+	}
+	for i := 0; i < len(tokens); i++ {
+		switch tokens[i] {
+		case "machine", "default":
+			add()
+			current, machine = &credentials{}, ""
+			if tokens[i] == "default" {
+				continue
+			}
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("machine without name")
+			}
+			i++
+			machine = tokens[i]
+
+		case "login", "password", "account":
+			if current == nil {
+				return nil, fmt.Errorf("%s before machine", tokens[i])
+			}
+			if i+1 >= len(tokens) {
+				return nil, fmt.Errorf("%s without value", tokens[i])
+			}
+			switch tokens[i] {
+			case "login":
+				current.username = tokens[i+1]
+			case "password":
+				current.password = tokens[i+1]
+			}
+			i++
+
+		default:
+			return nil, fmt.Errorf("unexpected token %q", tokens[i])
+		}
+	}
+	add()
+
+	return machines, nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
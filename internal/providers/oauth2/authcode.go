@@ -0,0 +1,215 @@
+package oauth2
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/nuttmeister/pm-creds/internal/oauth"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// SetAuthorizer sets the authorizer used to let the user authorize in a browser.
+// Returns true if any profile uses the authorization code flow.
+func (p *Provider) SetAuthorizer(authorizer types.Authorizer) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.authorizer = authorizer
+
+	for _, cfg := range p.profiles {
+		if cfg.Flow == FlowAuthorizationCode {
+			return true
+		}
+	}
+	return false
+}
+
+// SetPrompter sets the prompter used to ask for the passphrase of the token file.
+func (p *Provider) SetPrompter(prompter types.Prompter) {
+	if p.tokens != nil {
+		p.tokens.SetPrompter(prompter)
+	}
+}
+
+// errAuthorize is returned when the user must authorize in a browser but can't.
+var errAuthorize = errors.New("no refresh token and no way to authorize in a browser")
+
+// call is an authorization of a profile that is in progress.
+type call struct {
+	done        chan struct{}
+	interactive bool
+	token       *oauth.Token
+	err         error
+}
+
+// authorizationCode returns an access token for profile name. Only one authorization of a
+// profile is made at the time so the user isn't asked twice, concurrent calls for the same
+// profile waits for and returns the result of the authorization in progress. If interactive
+// is false the user is never asked to authorize in a browser.
+func (p *Provider) authorizationCode(name string, cfg *client, interactive bool) (*oauth.Token, error) {
+	p.mu.Lock()
+	for {
+		cl, ok := p.inflight[name]
+		if !ok {
+			break
+		}
+		p.mu.Unlock()
+		<-cl.done
+		// Calls that couldn't ask the user are made again by calls that can.
+		if !interactive || cl.interactive || !errors.Is(cl.err, errAuthorize) {
+			return cl.token, cl.err
+		}
+		p.mu.Lock()
+	}
+	cl := &call{done: make(chan struct{}), interactive: interactive}
+	p.inflight[name] = cl
+	authorizer := p.authorizer
+	if !interactive {
+		authorizer = nil
+	}
+	p.mu.Unlock()
+
+	cl.token, cl.err = p.authorize(name, cfg, authorizer)
+
+	p.mu.Lock()
+	delete(p.inflight, name)
+	p.mu.Unlock()
+	close(cl.done)
+
+	return cl.token, cl.err
+}
+
+// authorize returns an access token for profile name using the stored refresh token. If there
+// is no refresh token, or it's no longer valid, the user is asked to authorize in a browser
+// with authorizer and the code is exchanged using pkce.
+func (p *Provider) authorize(name string, cfg *client, authorizer types.Authorizer) (*oauth.Token, error) {
+	refreshToken, err := p.refreshToken(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if refreshToken != "" {
+		form := url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}}
+		token, err := p.request(cfg, form)
+		if err == nil {
+			if token.RefreshToken != "" && token.RefreshToken != refreshToken {
+				if err := p.storeRefreshToken(name, token.RefreshToken); err != nil {
+					return nil, err
+				}
+			}
+			return token, nil
+		}
+		if !oauth.IsCode(err, oauth.ErrInvalidGrant) {
+			return nil, err
+		}
+	}
+
+	if authorizer == nil {
+		return nil, errAuthorize
+	}
+
+	verifier, err := random()
+	if err != nil {
+		return nil, err
+	}
+	state, err := random()
+	if err != nil {
+		return nil, err
+	}
+
+	redirectURL := cfg.RedirectURL
+	if redirectURL == "" {
+		redirectURL = authorizer.CallbackURL()
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {cfg.ClientID},
+		"redirect_uri":          {redirectURL},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	if len(cfg.Scopes) > 0 {
+		query.Set("scope", strings.Join(cfg.Scopes, " "))
+	}
+	if cfg.Audience != "" {
+		query.Set("audience", cfg.Audience)
+	}
+
+	authURL := cfg.AuthURL + "?" + query.Encode()
+	if strings.Contains(cfg.AuthURL, "?") {
+		authURL = cfg.AuthURL + "&" + query.Encode()
+	}
+
+	code, err := authorizer.Authorize(authURL, state)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't authorize %q. %w", name, err)
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {redirectURL},
+		"code_verifier": {verifier},
+	}
+	token, err := p.request(cfg, form)
+	if err != nil {
+		return nil, err
+	}
+
+	if token.RefreshToken != "" {
+		if err := p.storeRefreshToken(name, token.RefreshToken); err != nil {
+			return nil, err
+		}
+	}
+
+	return token, nil
+}
+
+// refreshToken returns the stored refresh token for profile name or empty string if there is none.
+func (p *Provider) refreshToken(name string) (string, error) {
+	store, err := p.tokens.Open()
+	if err != nil {
+		return "", fmt.Errorf("couldn't open token file. %w", err)
+	}
+
+	fields, ok := store.Get(name)
+	if !ok {
+		return "", nil
+	}
+	token, _ := fields["refreshToken"].(string)
+	return token, nil
+}
+
+// storeRefreshToken will store the refresh token for profile name. The token file is only
+// updated by one profile at the time so that no refresh tokens are lost.
+func (p *Provider) storeRefreshToken(name string, token string) error {
+	p.tokensMu.Lock()
+	defer p.tokensMu.Unlock()
+
+	store, err := p.tokens.Open()
+	if err != nil {
+		return fmt.Errorf("couldn't open token file. %w", err)
+	}
+
+	store.Set(name, map[string]interface{}{"refreshToken": token})
+	if err := store.Save(); err != nil {
+		return fmt.Errorf("couldn't store refresh token. %w", err)
+	}
+	return nil
+}
+
+// random returns 32 random bytes base64 url encoded.
+func random() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("couldn't create random value. %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,273 @@
+package oauth2
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/crypt"
+	"github.com/stretchr/testify/assert"
+)
+
+// authServer is a stand-in for an authorization server supporting pkce and refresh tokens.
+type authServer struct {
+	mu         sync.Mutex
+	challenges map[string]string
+	refresh    map[string]bool
+	issued     int
+}
+
+func (a *authServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	r.ParseForm()
+	if r.PostForm.Get("client_id") != "public" {
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"invalid_client"}`)
+		return
+	}
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		challenge := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if a.challenges[r.PostForm.Get("code")] != base64.RawURLEncoding.EncodeToString(challenge[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant","error_description":"pkce verification failed"}`)
+			return
+		}
+		delete(a.challenges, r.PostForm.Get("code"))
+
+	case "refresh_token":
+		if !a.refresh[r.PostForm.Get("refresh_token")] {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"error":"invalid_grant"}`)
+			return
+		}
+		// Refresh tokens are rotated.
+		delete(a.refresh, r.PostForm.Get("refresh_token"))
+
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"error":"unsupported_grant_type"}`)
+		return
+	}
+
+	a.issued++
+	refresh := fmt.Sprintf("refresh-%d", a.issued)
+	a.refresh[refresh] = true
+	fmt.Fprintf(w, `{"access_token":"access-%d","token_type":"Bearer","expires_in":300,"refresh_token":%q}`, a.issued, refresh)
+}
+
+// authorizerMock acts as the user authorizing in a browser. If release is set
+// authorizations are signaled on started and waits until release is closed.
+type authorizerMock struct {
+	server     *authServer
+	authorized int
+	deny       bool
+	started    chan struct{}
+	release    chan struct{}
+}
+
+func (a *authorizerMock) CallbackURL() string {
+	return "https://localhost:9999/callback"
+}
+
+func (a *authorizerMock) Authorize(authURL string, state string) (string, error) {
+	if a.deny {
+		return "", fmt.Errorf("access_denied")
+	}
+	if a.release != nil {
+		a.started <- struct{}{}
+		<-a.release
+	}
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", err
+	}
+	query := u.Query()
+	if query.Get("state") != state || query.Get("code_challenge_method") != "S256" ||
+		query.Get("redirect_uri") != a.CallbackURL() || query.Get("scope") != "openid offline_access" {
+		return "", fmt.Errorf("invalid authorization request %q", authURL)
+	}
+
+	a.server.mu.Lock()
+	defer a.server.mu.Unlock()
+	a.authorized++
+	code := fmt.Sprintf("code-%d", a.authorized)
+	a.server.challenges[code] = query.Get("code_challenge")
+	return code, nil
+}
+
+func TestAuthorizationCode(t *testing.T) {
+	auth := &authServer{challenges: map[string]string{}, refresh: map[string]bool{}}
+	server := httptest.NewServer(auth)
+	defer server.Close()
+
+	dir := t.TempDir()
+	config := map[string]interface{}{
+		"flow":      "authorization-code",
+		"auth-url":  "https://auth.example.com/authorize",
+		"token-url": server.URL,
+		"client-id": "public",
+		"scopes":    []string{"openid", "offline_access"},
+	}
+	provider, err := Create(dir, "user", config)
+	assert.NoError(t, err)
+
+	// No way to authorize.
+	_, err = provider.Get("$default")
+	assert.Error(t, err)
+
+	authorizer := &authorizerMock{server: auth}
+	assert.True(t, provider.SetAuthorizer(authorizer))
+
+	// Refreshing never asks the user to authorize.
+	_, err = provider.Refresh("$default")
+	assert.ErrorIs(t, err, errAuthorize)
+	assert.Equal(t, 0, authorizer.authorized)
+
+	profile, err := provider.Get("$default")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, authorizer.authorized)
+	fields := map[string]string{}
+	assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+	assert.Equal(t, "access-1", fields["accessToken"])
+
+	info, err := os.Stat(filepath.Join(dir, "user-tokens.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// The refresh token is used, also by a new provider.
+	provider, err = Create(dir, "user", config)
+	assert.NoError(t, err)
+	provider.SetAuthorizer(authorizer)
+	for i := 2; i <= 3; i++ {
+		profile, err = provider.Get("$default")
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, fmt.Sprintf("access-%d", i), fields["accessToken"])
+	}
+	profile, err = provider.Refresh("$default")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+	assert.Equal(t, "access-4", fields["accessToken"])
+	assert.Equal(t, 1, authorizer.authorized)
+
+	// A revoked refresh token makes the user authorize again.
+	auth.refresh = map[string]bool{}
+	_, err = provider.Get("$default")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, authorizer.authorized)
+
+	// Denied authorization.
+	auth.refresh = map[string]bool{}
+	authorizer.deny = true
+	_, err = provider.Get("$default")
+	assert.Error(t, err)
+}
+
+func TestAuthorizationCodeConcurrent(t *testing.T) {
+	auth := &authServer{challenges: map[string]string{}, refresh: map[string]bool{}}
+	server := httptest.NewServer(auth)
+	defer server.Close()
+
+	provider, err := Create(t.TempDir(), "user", map[string]interface{}{
+		"flow":      "authorization-code",
+		"auth-url":  "https://auth.example.com/authorize",
+		"token-url": server.URL,
+		"client-id": "public",
+		"scopes":    []string{"openid", "offline_access"},
+		"profiles":  map[string]interface{}{"first": map[string]interface{}{}, "second": map[string]interface{}{}},
+	})
+	assert.NoError(t, err)
+	authorizer := &authorizerMock{server: auth, started: make(chan struct{}, 3), release: make(chan struct{})}
+	provider.SetAuthorizer(authorizer)
+
+	tokens := make(chan string, 3)
+	for _, name := range []string{"first", "first", "second"} {
+		go func(name string) {
+			profile, err := provider.Get(name)
+			assert.NoError(t, err)
+			fields := map[string]string{}
+			json.Unmarshal(profile.Payload(), &fields)
+			tokens <- name + ":" + fields["accessToken"]
+		}(name)
+	}
+
+	// Profiles are authorized at the same time but each profile only once.
+	for i := 0; i < 2; i++ {
+		select {
+		case <-authorizer.started:
+		case <-time.After(time.Second):
+			t.Fatal("authorization of other profile is blocked")
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(authorizer.release)
+
+	results := map[string]int{}
+	for i := 0; i < 3; i++ {
+		results[<-tokens]++
+	}
+	assert.Equal(t, 2, authorizer.authorized)
+	assert.Len(t, results, 2)
+	assert.Empty(t, authorizer.started)
+}
+
+func TestAuthorizationCodeEncrypted(t *testing.T) {
+	auth := &authServer{challenges: map[string]string{}, refresh: map[string]bool{}}
+	server := httptest.NewServer(auth)
+	defer server.Close()
+
+	os.Setenv("OAUTH2_TEST_PASSPHRASE", "passphrase")
+	defer os.Unsetenv("OAUTH2_TEST_PASSPHRASE")
+
+	fn := filepath.Join(t.TempDir(), "tokens.json")
+	provider, err := Create("", "user", map[string]interface{}{
+		"flow":           "authorization-code",
+		"auth-url":       "https://auth.example.com/authorize?tenant=x",
+		"token-url":      server.URL,
+		"client-id":      "public",
+		"scopes":         []string{"openid", "offline_access"},
+		"token-file":     fn,
+		"encrypt":        true,
+		"passphrase-env": "OAUTH2_TEST_PASSPHRASE",
+	})
+	assert.NoError(t, err)
+	provider.SetAuthorizer(&authorizerMock{server: auth})
+
+	_, err = provider.Get("$default")
+	assert.NoError(t, err)
+
+	raw, err := os.ReadFile(fn)
+	assert.NoError(t, err)
+	assert.True(t, crypt.IsSealed(raw))
+	assert.NotContains(t, string(raw), "refresh-1")
+}
+
+func TestAuthorizationCodeErrors(t *testing.T) {
+	_, err := Create(t.TempDir(), "user", map[string]interface{}{
+		"flow":      "authorization-code",
+		"token-url": "https://auth.example.com/token",
+		"client-id": "public",
+	})
+	assert.Error(t, err)
+
+	_, err = Create(t.TempDir(), "user", map[string]interface{}{
+		"flow":      "implicit",
+		"token-url": "https://auth.example.com/token",
+		"client-id": "public",
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,361 @@
+// Package oauth2 is a provider that can be used by the providers package to
+// retrieve access tokens from any token endpoint using the oauth2 client
+// credentials flow.
+package oauth2
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/oauth"
+	"github.com/nuttmeister/pm-creds/internal/paths"
+	"github.com/nuttmeister/pm-creds/internal/providers/secrets"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Flows supported.
+const (
+	FlowClientCredentials = "client-credentials"
+	FlowAuthorizationCode = "authorization-code"
+)
+
+// Client authentication methods supported.
+const (
+	AuthBasic = "basic"
+	AuthPost  = "post"
+)
+
+// Defaults used if not configured.
+const (
+	defaultTimeout = 30 * time.Second
+	defaultProfile = "$default"
+)
+
+// client is the configuration of a client. Profiles inherit the provider configuration.
+type client struct {
+	Flow             string            `mapstructure:"flow"`
+	AuthURL          string            `mapstructure:"auth-url"`
+	RedirectURL      string            `mapstructure:"redirect-url"`
+	TokenURL         string            `mapstructure:"token-url"`
+	ClientID         string            `mapstructure:"client-id"`
+	ClientSecret     string            `mapstructure:"client-secret"`
+	ClientSecretEnv  string            `mapstructure:"client-secret-env"`
+	ClientSecretFile string            `mapstructure:"client-secret-file"`
+	AuthMethod       string            `mapstructure:"auth-method"`
+	Scopes           []string          `mapstructure:"scopes"`
+	Audience         string            `mapstructure:"audience"`
+	Params           map[string]string `mapstructure:"params"`
+}
+
+// inherit will set all fields not set in c from parent.
+func (c *client) inherit(parent *client) {
+	if c.Flow == "" {
+		c.Flow = parent.Flow
+	}
+	if c.AuthURL == "" {
+		c.AuthURL = parent.AuthURL
+	}
+	if c.RedirectURL == "" {
+		c.RedirectURL = parent.RedirectURL
+	}
+	if c.TokenURL == "" {
+		c.TokenURL = parent.TokenURL
+	}
+	if c.ClientID == "" {
+		c.ClientID = parent.ClientID
+	}
+	if c.ClientSecret == "" && c.ClientSecretEnv == "" && c.ClientSecretFile == "" {
+		c.ClientSecret = parent.ClientSecret
+		c.ClientSecretEnv = parent.ClientSecretEnv
+		c.ClientSecretFile = parent.ClientSecretFile
+	}
+	if c.AuthMethod == "" {
+		c.AuthMethod = parent.AuthMethod
+	}
+	if len(c.Scopes) == 0 {
+		c.Scopes = parent.Scopes
+	}
+	if c.Audience == "" {
+		c.Audience = parent.Audience
+	}
+	params := map[string]string{}
+	for key, value := range parent.Params {
+		params[key] = value
+	}
+	for key, value := range c.Params {
+		params[key] = value
+	}
+	c.Params = params
+}
+
+// validate returns error if c is missing required fields.
+func (c *client) validate() error {
+	if _, err := url.ParseRequestURI(c.TokenURL); err != nil {
+		return fmt.Errorf("couldn't parse %s. %w", "token-url", err)
+	}
+	if c.ClientID == "" {
+		return fmt.Errorf("no %s set", "client-id")
+	}
+	c.Flow = strings.ToLower(c.Flow)
+	switch c.Flow {
+	case FlowClientCredentials:
+	case FlowAuthorizationCode:
+		if _, err := url.ParseRequestURI(c.AuthURL); err != nil {
+			return fmt.Errorf("couldn't parse %s. %w", "auth-url", err)
+		}
+	default:
+		return fmt.Errorf("invalid %s %q", "flow", c.Flow)
+	}
+	c.AuthMethod = strings.ToLower(c.AuthMethod)
+	if c.AuthMethod != AuthBasic && c.AuthMethod != AuthPost {
+		return fmt.Errorf("invalid %s %q", "auth-method", c.AuthMethod)
+	}
+	return nil
+}
+
+// secret returns the client secret from the configured source.
+func (c *client) secret() (string, error) {
+	switch {
+	case c.ClientSecretEnv != "":
+		secret := os.Getenv(c.ClientSecretEnv)
+		if secret == "" {
+			return "", fmt.Errorf("client secret variable %s isn't set", c.ClientSecretEnv)
+		}
+		return secret, nil
+
+	case c.ClientSecretFile != "":
+		raw, err := os.ReadFile(c.ClientSecretFile)
+		if err != nil {
+			return "", fmt.Errorf("couldn't read client secret file %q. %w", c.ClientSecretFile, err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+
+	default:
+		return c.ClientSecret, nil
+	}
+}
+
+// Create will create a new provider with name based on config and return it.
+// Refresh tokens are stored in cfgDir unless token-file is set.
+func Create(cfgDir string, name string, raw map[string]interface{}) (*Provider, error) {
+	defaults := &client{Flow: FlowClientCredentials, AuthMethod: AuthBasic}
+	if err := mapstructure.Decode(raw, defaults); err != nil {
+		return nil, fmt.Errorf("oauth2: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	data := &struct {
+		Timeout        string             `mapstructure:"timeout"`
+		TokenFile      string             `mapstructure:"token-file"`
+		Encrypt        bool               `mapstructure:"encrypt"`
+		PassphraseEnv  *string            `mapstructure:"passphrase-env"`
+		PassphraseFile string             `mapstructure:"passphrase-file"`
+		Profiles       map[string]*client `mapstructure:"profiles"`
+	}{TokenFile: paths.TokensFile(cfgDir, name)}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("oauth2: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	timeout := defaultTimeout
+	if data.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(data.Timeout); err != nil {
+			return nil, fmt.Errorf("oauth2: couldn't parse %s for %q. %w", "timeout", name, err)
+		}
+	}
+
+	profiles := map[string]*client{}
+	if defaults.TokenURL != "" && defaults.ClientID != "" {
+		profiles[defaultProfile] = &client{}
+	}
+	for profileName, cfg := range data.Profiles {
+		profiles[profileName] = cfg
+	}
+	for profileName, cfg := range profiles {
+		cfg.inherit(defaults)
+		if err := cfg.validate(); err != nil {
+			return nil, fmt.Errorf("oauth2: profile %q for %q is invalid. %w", profileName, name, err)
+		}
+	}
+
+	provider := &Provider{
+		name:     name,
+		profiles: profiles,
+		client:   &http.Client{Timeout: timeout},
+		inflight: map[string]*call{},
+	}
+
+	for _, cfg := range profiles {
+		if cfg.Flow != FlowAuthorizationCode {
+			continue
+		}
+
+		// Refresh tokens are stored in a secrets file that can be encrypted.
+		store := map[string]interface{}{
+			"file":            data.TokenFile,
+			"encrypt":         data.Encrypt,
+			"passphrase-file": data.PassphraseFile,
+		}
+		if data.PassphraseEnv != nil {
+			store["passphrase-env"] = *data.PassphraseEnv
+		}
+		tokens, err := secrets.Create(cfgDir, name, store)
+		if err != nil {
+			return nil, fmt.Errorf("oauth2: couldn't create token store for %q. %w", name, err)
+		}
+		provider.tokens = tokens
+		break
+	}
+
+	return provider, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	profiles map[string]*client
+	client   *http.Client
+	tokens   *secrets.Provider
+
+	// tokensMu is held while the token file is updated.
+	tokensMu sync.Mutex
+
+	mu         sync.Mutex
+	authorizer types.Authorizer
+	inflight   map[string]*call
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will request an access token for profile name. Profile $default uses the
+// client configured for the provider.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	return p.get(name, true)
+}
+
+// Refresh will request an access token for profile name like Get, but never asks
+// the user to authorize in a browser.
+func (p *Provider) Refresh(name string) (types.Profile, error) {
+	return p.get(name, false)
+}
+
+// get will request an access token for profile name. If interactive is false the
+// user is never asked to authorize in a browser.
+func (p *Provider) get(name string, interactive bool) (types.Profile, error) {
+	cfg, ok := p.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("oauth2: no profile %q in %q", name, p.Name())
+	}
+
+	var token *oauth.Token
+	var err error
+	switch cfg.Flow {
+	case FlowAuthorizationCode:
+		token, err = p.authorizationCode(name, cfg, interactive)
+	default:
+		form := url.Values{"grant_type": {"client_credentials"}}
+		if len(cfg.Scopes) > 0 {
+			form.Set("scope", strings.Join(cfg.Scopes, " "))
+		}
+		token, err = p.request(cfg, form)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: couldn't get token for %q from %q. %w", name, p.Name(), err)
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("oauth2: no access token returned for %q from %q", name, p.Name())
+	}
+
+	payload, err := json.Marshal(token.Payload())
+	if err != nil {
+		return nil, fmt.Errorf("oauth2: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:    name,
+		payload: payload,
+		metadata: types.Metadata{
+			Expires: token.Expires,
+			Source:  fmt.Sprintf("oauth2: %s", cfg.TokenURL),
+			Kind:    types.KindSession,
+		},
+	}, nil
+}
+
+// request will make a token request with form authenticated as the client of cfg.
+// The audience and extra parameters of cfg are added to form.
+func (p *Provider) request(cfg *client, form url.Values) (*oauth.Token, error) {
+	secret, err := cfg.secret()
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range cfg.Params {
+		form.Set(key, value)
+	}
+	if cfg.Audience != "" {
+		form.Set("audience", cfg.Audience)
+	}
+
+	header := http.Header{}
+	switch {
+	case secret == "":
+		// Public clients only identify themselves.
+		form.Set("client_id", cfg.ClientID)
+	case cfg.AuthMethod == AuthBasic:
+		// Client id and secret are form encoded before used as basic auth, see rfc 6749 section 2.3.1.
+		auth := url.QueryEscape(cfg.ClientID) + ":" + url.QueryEscape(secret)
+		header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(auth)))
+	case cfg.AuthMethod == AuthPost:
+		form.Set("client_id", cfg.ClientID)
+		form.Set("client_secret", secret)
+	}
+
+	return oauth.Request(p.client, cfg.TokenURL, form, header)
+}
+
+// Files returns the client secret files used.
+func (p *Provider) Files() []string {
+	files := []string{}
+	for _, cfg := range p.profiles {
+		if cfg.ClientSecretFile != "" {
+			files = append(files, cfg.ClientSecretFile)
+		}
+	}
+	return files
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
@@ -0,0 +1,143 @@
+package oauth2
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// clients are the client secrets accepted by the token stub.
+var clients = map[string]string{
+	"client": "secret",
+	"orders": "orders:secret",
+}
+
+// tokenStub is a stand-in for a token endpoint. The token returned contains the
+// client, scope, audience and extra parameter so they can be verified.
+func tokenStub() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+
+		if r.PostForm.Get("grant_type") != "client_credentials" || clients[id] == "" || clients[id] != secret {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprint(w, `{"error":"invalid_client"}`)
+			return
+		}
+
+		token := fmt.Sprintf("%s|%s|%s|%s", id, r.PostForm.Get("scope"), r.PostForm.Get("audience"), r.PostForm.Get("resource"))
+		if id == "orders" {
+			fmt.Fprintf(w, `{"access_token":%q,"token_type":"bearer"}`, token)
+			return
+		}
+		fmt.Fprintf(w, `{"access_token":%q,"token_type":"Bearer","expires_in":300,"scope":%q}`, token, r.PostForm.Get("scope"))
+	}))
+}
+
+func TestProvider(t *testing.T) {
+	server := tokenStub()
+	defer server.Close()
+
+	fn := filepath.Join(t.TempDir(), "secret")
+	assert.NoError(t, os.WriteFile(fn, []byte("orders:secret\n"), 0600))
+	os.Setenv("OAUTH2_TEST_SECRET", "secret")
+	defer os.Unsetenv("OAUTH2_TEST_SECRET")
+
+	provider, err := Create(t.TempDir(), "oauth2", map[string]interface{}{
+		"token-url":         server.URL,
+		"client-id":         "client",
+		"client-secret-env": "OAUTH2_TEST_SECRET",
+		"scopes":            []string{"read", "write"},
+		"params":            map[string]interface{}{"resource": "api"},
+		"profiles": map[string]interface{}{
+			"audience": map[string]interface{}{
+				"audience": "https://api.example.com",
+				"scopes":   []string{"read"},
+			},
+			"orders": map[string]interface{}{
+				"client-id":          "orders",
+				"client-secret-file": fn,
+				"auth-method":        "POST",
+				"params":             map[string]interface{}{"resource": "orders"},
+			},
+			"wrong-secret": map[string]interface{}{
+				"client-secret": "wrong",
+			},
+			"missing-secret": map[string]interface{}{
+				"client-secret-env": "OAUTH2_TEST_NOT_SET",
+			},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "oauth2", provider.Name())
+	assert.Equal(t, []string{fn}, provider.Files())
+	assert.False(t, provider.SetAuthorizer(nil))
+
+	for name, expected := range map[string]string{
+		"$default": "client|read write||api",
+		"audience": "client|read|https://api.example.com|api",
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err, name)
+		if err != nil {
+			continue
+		}
+		assert.Equal(t, name, profile.Name())
+		assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+		assert.WithinDuration(t, time.Now().Add(5*time.Minute), profile.Metadata().Expires, time.Minute)
+
+		fields := map[string]string{}
+		assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+		assert.Equal(t, expected, fields["accessToken"])
+		assert.Equal(t, "Bearer", fields["tokenType"])
+		assert.Equal(t, profile.Metadata().Expires.UTC().Format(time.RFC3339), fields["expiration"])
+	}
+
+	profile, err := provider.Get("orders")
+	assert.NoError(t, err)
+	assert.True(t, profile.Metadata().Expires.IsZero())
+	assert.JSONEq(t, `{"accessToken":"orders|read write||orders","tokenType":"bearer"}`, string(profile.Payload()))
+
+	for _, name := range []string{"wrong-secret", "missing-secret", "notexist"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestNoDefault(t *testing.T) {
+	provider, err := Create(t.TempDir(), "oauth2", map[string]interface{}{
+		"token-url": "https://auth.example.com/token",
+		"profiles": map[string]interface{}{
+			"orders": map[string]interface{}{"client-id": "orders"},
+		},
+	})
+	assert.NoError(t, err)
+
+	_, err = provider.Get("$default")
+	assert.EqualError(t, err, `oauth2: no profile "$default" in "oauth2"`)
+}
+
+func TestCreateErrors(t *testing.T) {
+	for _, config := range []map[string]interface{}{
+		{"token-url": "https://auth.example.com/token", "client-id": "client", "timeout": "forever"},
+		{"token-url": "https://auth.example.com/token", "client-id": "client", "auth-method": "jwt"},
+		{"token-url": "not a url", "client-id": "client"},
+		{"profiles": map[string]interface{}{"x": map[string]interface{}{"client-id": "client"}}},
+		{"token-url": "https://auth.example.com/token", "profiles": map[string]interface{}{"x": map[string]interface{}{}}},
+		{"scopes": "read"},
+	} {
+		_, err := Create(t.TempDir(), "oauth2", config)
+		assert.Error(t, err, config)
+	}
+}
@@ -6,16 +6,37 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/nuttmeister/pm-creds/internal/paths"
 	"github.com/nuttmeister/pm-creds/internal/providers/aws"
+	"github.com/nuttmeister/pm-creds/internal/providers/azure"
+	"github.com/nuttmeister/pm-creds/internal/providers/docker"
+	"github.com/nuttmeister/pm-creds/internal/providers/env"
+	"github.com/nuttmeister/pm-creds/internal/providers/exec"
+	"github.com/nuttmeister/pm-creds/internal/providers/gcp"
+	"github.com/nuttmeister/pm-creds/internal/providers/github"
+	"github.com/nuttmeister/pm-creds/internal/providers/gitlab"
+	"github.com/nuttmeister/pm-creds/internal/providers/jwt"
+	"github.com/nuttmeister/pm-creds/internal/providers/kubeconfig"
+	"github.com/nuttmeister/pm-creds/internal/providers/netrc"
+	"github.com/nuttmeister/pm-creds/internal/providers/oauth2"
+	"github.com/nuttmeister/pm-creds/internal/providers/secrets"
 	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/nuttmeister/pm-creds/internal/providers/vault"
 	"github.com/pelletier/go-toml"
 )
 
-// Providers contains all providers loaded.
+// cacheDefaults are the provider types that are cached unless cache is set to false.
+var cacheDefaults = map[string]bool{
+	"oauth2": true,
+}
+
+// Providers contains all providers loaded. Providers with cache enabled
+// are wrapped in a cache.
 type Providers struct {
 	providers map[string]types.Provider
 }
@@ -30,6 +51,51 @@ func (p *Providers) Get(name string) (types.Provider, error) {
 	return provider, nil
 }
 
+// Names returns the names of all providers loaded sorted.
+func (p *Providers) Names() []string {
+	names := []string{}
+	for name := range p.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Unwrap will return the provider with name without the cache it might be wrapped in.
+func (p *Providers) Unwrap(name string) (types.Provider, error) {
+	provider, err := p.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := provider.(*cache); ok {
+		return c.provider, nil
+	}
+
+	return provider, nil
+}
+
+// SetPrompter will set prompter on all providers that needs to ask the user for input.
+func (p *Providers) SetPrompter(prompter types.Prompter) {
+	for _, provider := range p.providers {
+		if setter, ok := provider.(types.PromptSetter); ok {
+			setter.SetPrompter(prompter)
+		}
+	}
+}
+
+// SetAuthorizer will set authorizer on all providers that needs the user to authorize in a browser.
+// Returns true if any provider has profiles that uses the authorizer.
+func (p *Providers) SetAuthorizer(authorizer types.Authorizer) bool {
+	used := false
+	for _, provider := range p.providers {
+		if setter, ok := provider.(types.AuthorizerSetter); ok && setter.SetAuthorizer(authorizer) {
+			used = true
+		}
+	}
+	return used
+}
+
 // Load will load and create providers from config directory cfgDir.
 func Load(cfgDir string) (*Providers, error) {
 	providers := map[string]types.Provider{}
@@ -40,7 +106,7 @@ func Load(cfgDir string) (*Providers, error) {
 	}
 
 	for name, data := range rawProviders {
-		provider, err := parseProvider(name, data)
+		provider, err := parseProvider(cfgDir, name, data)
 		if err != nil {
 			return nil, fmt.Errorf("providers: couldn't parse providers. %w", err)
 		}
@@ -73,24 +139,76 @@ func loadProviders(cfgDir string) (map[string]interface{}, error) {
 // parseProvider will parse the provider data to make sure it satisfies the minimum data
 // required for it to be created. It will also call the corrept provider package
 // depending on what type was set in the data provided.
-func parseProvider(name string, data interface{}) (types.Provider, error) {
+func parseProvider(cfgDir string, name string, data interface{}) (types.Provider, error) {
 	raw, ok := data.(map[string]interface{})
 	if !ok {
 		return nil, fmt.Errorf("couldn't read config of provider %q", name)
 	}
 
 	cfg := &struct {
-		Type string `mapstructure:"type"`
+		Type     string `mapstructure:"type"`
+		Cache    *bool  `mapstructure:"cache"`
+		CacheTTL string `mapstructure:"cache-ttl"`
 	}{}
 	if err := mapstructure.Decode(raw, cfg); err != nil {
 		return nil, fmt.Errorf("couldn't decode field %s from data for %q. %w", "type", name, err)
 	}
 
+	cache := cacheDefaults[strings.ToLower(cfg.Type)]
+	if cfg.Cache != nil {
+		cache = *cfg.Cache
+	}
+
+	var provider types.Provider
+	var err error
+
 	// Add more providers that satisfies the Provider interface here.
 	switch strings.ToLower(cfg.Type) {
 	case "aws":
-		return aws.Create(name, raw)
+		provider, err = aws.Create(name, raw)
+	case "exec":
+		provider, err = exec.Create(name, raw)
+	case "azure":
+		provider, err = azure.Create(name, raw)
+	case "env":
+		provider, err = env.Create(name, raw)
+	case "gcp":
+		provider, err = gcp.Create(name, raw)
+	case "jwt":
+		provider, err = jwt.Create(name, raw)
+	case "kubeconfig":
+		provider, err = kubeconfig.Create(name, raw)
+	case "docker":
+		provider, err = docker.Create(name, raw)
+	case "netrc":
+		provider, err = netrc.Create(name, raw)
+	case "github":
+		provider, err = github.Create(name, raw)
+	case "gitlab":
+		provider, err = gitlab.Create(name, raw)
+	case "file":
+		provider, err = secrets.Create(cfgDir, name, raw)
+	case "oauth2":
+		provider, err = oauth2.Create(cfgDir, name, raw)
+	case "vault":
+		provider, err = vault.Create(name, raw)
+	default:
+		return nil, fmt.Errorf("provider %q has an invalid %q", cfg.Type, "type")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !cache {
+		return provider, nil
+	}
+
+	ttl := time.Duration(0)
+	if cfg.CacheTTL != "" {
+		if ttl, err = time.ParseDuration(cfg.CacheTTL); err != nil {
+			return nil, fmt.Errorf("couldn't parse %s for %q. %w", "cache-ttl", name, err)
+		}
 	}
 
-	return nil, fmt.Errorf("provider %q has an invalid %q", cfg.Type, "type")
+	return newCache(provider, ttl), nil
 }
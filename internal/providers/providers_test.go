@@ -3,6 +3,7 @@ package providers
 import (
 	"testing"
 
+	"github.com/nuttmeister/pm-creds/internal/providers/aws"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -14,7 +15,7 @@ var tests = []struct {
 }{
 	{
 		cfgDir: "./testdata/working",
-		load:   []string{"aws-full", "aws-credentials", "aws-configs", "aws-default"},
+		load:   []string{"aws-full", "aws-credentials", "aws-configs", "aws-default", "aws-cached", "exec", "file", "jwt", "kubeconfig", "docker", "netrc", "github", "gitlab"},
 	},
 	{
 		cfgDir:  "./testdata/working",
@@ -41,6 +42,10 @@ var tests = []struct {
 		cfgDir: "./testdata/error-wrong-type",
 		err:    true,
 	},
+	{
+		cfgDir: "./testdata/error-cache-ttl",
+		err:    true,
+	},
 }
 
 func TestLoad(t *testing.T) {
@@ -65,3 +70,31 @@ func TestLoad(t *testing.T) {
 		}
 	}
 }
+
+func TestUnwrap(t *testing.T) {
+	providers, err := Load("./testdata/working")
+	assert.NoError(t, err)
+
+	cached, err := providers.Get("aws-cached")
+	assert.NoError(t, err)
+	assert.IsType(t, &cache{}, cached)
+
+	provider, err := providers.Unwrap("aws-cached")
+	assert.NoError(t, err)
+	assert.IsType(t, &aws.Provider{}, provider)
+
+	_, err = providers.Unwrap("no-exists")
+	assert.Error(t, err)
+}
+
+func TestCacheDefaults(t *testing.T) {
+	providers, err := Load("./testdata/working")
+	assert.NoError(t, err)
+
+	for name, cached := range map[string]bool{"oauth2": true, "oauth2-uncached": false, "aws-cached": true, "exec": false} {
+		provider, err := providers.Get(name)
+		assert.NoError(t, err)
+		_, ok := provider.(*cache)
+		assert.Equal(t, cached, ok, name)
+	}
+}
@@ -0,0 +1,378 @@
+// Package secrets is a provider that can be used by the providers package to
+// retrieve static secrets, such as api keys or passwords, from a secrets file.
+// The secrets file can optionally be encrypted at rest.
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/crypt"
+	"github.com/nuttmeister/pm-creds/internal/paths"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/pelletier/go-toml"
+)
+
+// defaultPassphraseEnv is the environmental variable the passphrase is read from if not configured.
+const defaultPassphraseEnv = "PM_CREDS_PASSPHRASE"
+
+// Create will create a new provider with name based on config and return it.
+// Relative file paths are relative to cfgDir.
+func Create(cfgDir string, name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		File           string `mapstructure:"file"`
+		Encrypt        bool   `mapstructure:"encrypt"`
+		PassphraseEnv  string `mapstructure:"passphrase-env"`
+		PassphraseFile string `mapstructure:"passphrase-file"`
+	}{File: paths.SecretsFile(cfgDir), PassphraseEnv: defaultPassphraseEnv}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("secrets: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	fn := data.File
+	if !filepath.IsAbs(fn) {
+		fn = filepath.Join(cfgDir, fn)
+	}
+
+	return &Provider{
+		name:           name,
+		file:           fn,
+		encrypt:        data.Encrypt,
+		passphraseEnv:  data.PassphraseEnv,
+		passphraseFile: data.PassphraseFile,
+	}, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	file           string
+	encrypt        bool
+	passphraseEnv  string
+	passphraseFile string
+
+	mu         sync.Mutex
+	prompter   types.Prompter
+	passphrase string
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve profile name from the secrets file.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	store, err := p.Open()
+	if err != nil {
+		return nil, fmt.Errorf("secrets: couldn't get secrets for %q from %q. %w", name, p.Name(), err)
+	}
+
+	fields, ok := store.profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("secrets: no profile %q in %q", name, p.Name())
+	}
+
+	payload, err := json.Marshal(fields)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: couldn't json marshal %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{
+		name:     name,
+		payload:  payload,
+		metadata: types.Metadata{Source: fmt.Sprintf("file: %s", p.file), Kind: types.KindSecret},
+	}, nil
+}
+
+// Files returns the secrets file.
+func (p *Provider) Files() []string {
+	return []string{p.file}
+}
+
+// File returns the path of the secrets file.
+func (p *Provider) File() string {
+	return p.file
+}
+
+// SetPrompter sets the prompter used to ask for the passphrase.
+func (p *Provider) SetPrompter(prompter types.Prompter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.prompter = prompter
+}
+
+// Open will read and if needed decrypt the secrets file. If the file doesn't
+// exist an empty store is returned.
+func (p *Provider) Open() (*Store, error) {
+	store := &Store{provider: p, profiles: map[string]map[string]interface{}{}}
+
+	raw, err := os.ReadFile(p.file)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return store, nil
+		}
+		return nil, fmt.Errorf("couldn't read file %q. %w", p.file, err)
+	}
+
+	if crypt.IsSealed(raw) {
+		store.sealed = true
+
+		passphrase, err := p.getPassphrase(false)
+		if err != nil {
+			return nil, err
+		}
+		if raw, err = crypt.Open(passphrase, raw); err != nil {
+			p.forgetPassphrase()
+			return nil, fmt.Errorf("couldn't decrypt file %q. %w", p.file, err)
+		}
+	}
+
+	if err := p.unmarshal(raw, store.profiles); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// unmarshal will unmarshal raw into profiles as json or toml depending on file extension.
+func (p *Provider) unmarshal(raw []byte, profiles map[string]map[string]interface{}) error {
+	if p.isJSON() {
+		if err := json.Unmarshal(raw, &profiles); err != nil {
+			return fmt.Errorf("couldn't json unmarshal file %q. %w", p.file, err)
+		}
+		return nil
+	}
+
+	tree := map[string]interface{}{}
+	if err := toml.Unmarshal(raw, &tree); err != nil {
+		return fmt.Errorf("couldn't toml unmarshal file %q. %w", p.file, err)
+	}
+	for name, data := range tree {
+		fields, ok := data.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("profile %q in file %q must be a table", name, p.file)
+		}
+		profiles[name] = fields
+	}
+
+	return nil
+}
+
+// isJSON returns true if the secrets file is a json file.
+func (p *Provider) isJSON() bool {
+	return strings.ToLower(filepath.Ext(p.file)) == ".json"
+}
+
+// getPassphrase returns the passphrase from the configured environmental variable or file.
+// Otherwise the user is asked for it, and if confirm is true asked to repeat it.
+func (p *Provider) getPassphrase(confirm bool) (string, error) {
+	if passphrase := os.Getenv(p.passphraseEnv); p.passphraseEnv != "" && passphrase != "" {
+		return passphrase, nil
+	}
+
+	if p.passphraseFile != "" {
+		raw, err := os.ReadFile(p.passphraseFile)
+		if err != nil {
+			return "", fmt.Errorf("couldn't read passphrase file %q. %w", p.passphraseFile, err)
+		}
+		return strings.TrimSpace(string(raw)), nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.passphrase != "" {
+		return p.passphrase, nil
+	}
+	if p.prompter == nil {
+		return "", fmt.Errorf("no passphrase for %q. set %s", p.file, p.passphraseEnv)
+	}
+
+	passphrase, err := p.prompter.PromptSecret(context.Background(), "enter passphrase for secrets file %q (%s): ", p.file, p.name)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get passphrase for %q, set %s or %s. %w", p.file, "passphrase-env", "passphrase-file", err)
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("no passphrase entered for %q", p.file)
+	}
+
+	if confirm {
+		repeated, err := p.prompter.PromptSecret(context.Background(), "repeat passphrase for secrets file %q (%s): ", p.file, p.name)
+		if err != nil {
+			return "", fmt.Errorf("couldn't get passphrase for %q. %w", p.file, err)
+		}
+		if repeated != passphrase {
+			return "", fmt.Errorf("passphrases for %q doesn't match", p.file)
+		}
+	}
+
+	p.passphrase = passphrase
+	return passphrase, nil
+}
+
+// forgetPassphrase will forget the passphrase entered by the user.
+func (p *Provider) forgetPassphrase() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.passphrase = ""
+}
+
+// Store is the content of a secrets file and can be used to manage its profiles.
+type Store struct {
+	provider *Provider
+	sealed   bool
+	profiles map[string]map[string]interface{}
+}
+
+// Profiles returns the sorted names of all profiles in the store.
+func (s *Store) Profiles() []string {
+	names := []string{}
+	for name := range s.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Fields returns the sorted field names of profile name.
+func (s *Store) Fields(name string) []string {
+	fields := []string{}
+	for field := range s.profiles[name] {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Get returns the fields of profile name and true if it exists.
+func (s *Store) Get(name string) (map[string]interface{}, bool) {
+	fields, ok := s.profiles[name]
+	return fields, ok
+}
+
+// Set will add profile name with fields or replace it if it already exists.
+func (s *Store) Set(name string, fields map[string]interface{}) {
+	s.profiles[name] = fields
+}
+
+// Add will add profile name with fields. Returns error if the profile already exists.
+func (s *Store) Add(name string, fields map[string]interface{}) error {
+	if _, ok := s.profiles[name]; ok {
+		return fmt.Errorf("secrets: profile %q already exists in %q", name, s.provider.Name())
+	}
+	s.profiles[name] = fields
+	return nil
+}
+
+// Rotate will replace the values of fields in profile name. Fields not in fields are kept.
+// Returns error if the profile doesn't exist.
+func (s *Store) Rotate(name string, fields map[string]interface{}) error {
+	profile, ok := s.profiles[name]
+	if !ok {
+		return fmt.Errorf("secrets: no profile %q in %q", name, s.provider.Name())
+	}
+	for field, value := range fields {
+		profile[field] = value
+	}
+	return nil
+}
+
+// Remove will remove profile name. Returns error if the profile doesn't exist.
+func (s *Store) Remove(name string) error {
+	if _, ok := s.profiles[name]; !ok {
+		return fmt.Errorf("secrets: no profile %q in %q", name, s.provider.Name())
+	}
+	delete(s.profiles, name)
+	return nil
+}
+
+// Save will write the store to the secrets file. The file is encrypted if the
+// provider is configured to encrypt or if the file already was encrypted.
+func (s *Store) Save() error {
+	p := s.provider
+
+	var raw []byte
+	var err error
+	switch p.isJSON() {
+	case true:
+		raw, err = json.MarshalIndent(s.profiles, "", "  ")
+	case false:
+		tree := map[string]interface{}{}
+		for name, fields := range s.profiles {
+			tree[name] = fields
+		}
+		raw, err = toml.Marshal(tree)
+	}
+	if err != nil {
+		return fmt.Errorf("secrets: couldn't marshal secrets for %q. %w", p.file, err)
+	}
+
+	if s.sealed || p.encrypt {
+		passphrase, err := p.getPassphrase(!s.sealed)
+		if err != nil {
+			return fmt.Errorf("secrets: %w", err)
+		}
+		if raw, err = crypt.Seal(passphrase, raw); err != nil {
+			return fmt.Errorf("secrets: couldn't encrypt secrets for %q. %w", p.file, err)
+		}
+	}
+
+	return writeFile(p.file, raw)
+}
+
+// writeFile will write raw to a temporary file and then replace fn with it.
+func writeFile(fn string, raw []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(fn), ".secrets-*")
+	if err != nil {
+		return fmt.Errorf("secrets: couldn't create temporary file for %q. %w", fn, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(raw); err != nil {
+		tmp.Close()
+		return fmt.Errorf("secrets: couldn't write temporary file for %q. %w", fn, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("secrets: couldn't write temporary file for %q. %w", fn, err)
+	}
+
+	if err := os.Rename(tmp.Name(), fn); err != nil {
+		return fmt.Errorf("secrets: couldn't replace file %q. %w", fn, err)
+	}
+
+	return nil
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
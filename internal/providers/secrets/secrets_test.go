@@ -0,0 +1,217 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nuttmeister/pm-creds/internal/crypt"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+var tests = []struct {
+	name          string
+	config        map[string]interface{}
+	profiles      map[string]string
+	profilesError []string
+}{
+	{
+		name:   "file-toml",
+		config: map[string]interface{}{"file": "secrets.toml"},
+		profiles: map[string]string{
+			"github":   `{"token":"ghp_secret"}`,
+			"database": `{"password":"db-secret","port":5432,"username":"admin"}`,
+		},
+		profilesError: []string{"notexist"},
+	},
+	{
+		name:   "file-json",
+		config: map[string]interface{}{"file": "secrets.json"},
+		profiles: map[string]string{
+			"api": `{"key":"json-secret"}`,
+		},
+		profilesError: []string{"github"},
+	},
+	{
+		name:          "file-invalid",
+		config:        map[string]interface{}{"file": "invalid.toml"},
+		profilesError: []string{"token"},
+	},
+	{
+		name:          "file-missing",
+		config:        map[string]interface{}{"file": "missing.toml"},
+		profilesError: []string{"github"},
+	},
+}
+
+func TestProvider(t *testing.T) {
+	for _, test := range tests {
+		provider, err := Create("./testdata", test.name, test.config)
+		assert.NoError(t, err)
+		assert.Equal(t, test.name, provider.Name())
+		assert.Equal(t, []string{filepath.Join("testdata", test.config["file"].(string))}, provider.Files())
+
+		for name, payload := range test.profiles {
+			profile, err := provider.Get(name)
+			assert.NoError(t, err)
+			assert.Equal(t, name, profile.Name())
+			assert.JSONEq(t, payload, string(profile.Payload()))
+			assert.Equal(t, types.KindSecret, profile.Metadata().Kind)
+		}
+
+		for _, name := range test.profilesError {
+			_, err := provider.Get(name)
+			assert.Error(t, err)
+		}
+	}
+}
+
+func TestCreate(t *testing.T) {
+	provider, err := Create("/config", "default", map[string]interface{}{})
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join("/config", "secrets.toml"), provider.File())
+	assert.Equal(t, defaultPassphraseEnv, provider.passphraseEnv)
+
+	provider, err = Create("/config", "absolute", map[string]interface{}{"file": "/secrets/file.json"})
+	assert.NoError(t, err)
+	assert.Equal(t, "/secrets/file.json", provider.File())
+
+	_, err = Create("/config", "error", map[string]interface{}{"encrypt": "maybe"})
+	assert.Error(t, err)
+}
+
+type prompterMock struct {
+	answers []string
+	prompts int
+}
+
+func (p *prompterMock) Prompt(ctx context.Context, format string, a ...interface{}) (string, error) {
+	return "", fmt.Errorf("passphrase asked for with echo")
+}
+
+func (p *prompterMock) PromptSecret(ctx context.Context, format string, a ...interface{}) (string, error) {
+	if p.prompts >= len(p.answers) {
+		return "", fmt.Errorf("no more answers")
+	}
+	p.prompts++
+	return p.answers[p.prompts-1], nil
+}
+
+func TestStore(t *testing.T) {
+	for _, ext := range []string{"toml", "json"} {
+		fn := filepath.Join(t.TempDir(), "secrets."+ext)
+		provider, err := Create("", "store", map[string]interface{}{"file": fn})
+		assert.NoError(t, err)
+
+		store, err := provider.Open()
+		assert.NoError(t, err)
+		assert.Empty(t, store.Profiles())
+
+		assert.NoError(t, store.Add("api", map[string]interface{}{"key": "old", "user": "user"}))
+		assert.NoError(t, store.Add("other", map[string]interface{}{"token": "token"}))
+		assert.Error(t, store.Add("api", map[string]interface{}{"key": "new"}))
+		assert.NoError(t, store.Save())
+
+		info, err := os.Stat(fn)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		store, err = provider.Open()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"api", "other"}, store.Profiles())
+		assert.Equal(t, []string{"key", "user"}, store.Fields("api"))
+
+		assert.NoError(t, store.Rotate("api", map[string]interface{}{"key": "new"}))
+		assert.Error(t, store.Rotate("notexist", map[string]interface{}{"key": "new"}))
+		fields, ok := store.Get("api")
+		assert.True(t, ok)
+		assert.Equal(t, "new", fields["key"])
+		store.Set("replaced", map[string]interface{}{"key": "first"})
+		store.Set("replaced", map[string]interface{}{"key": "second"})
+		fields, _ = store.Get("replaced")
+		assert.Equal(t, map[string]interface{}{"key": "second"}, fields)
+		assert.NoError(t, store.Remove("replaced"))
+
+		assert.NoError(t, store.Remove("other"))
+		assert.Error(t, store.Remove("other"))
+		assert.NoError(t, store.Save())
+
+		profile, err := provider.Get("api")
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"key":"new","user":"user"}`, string(profile.Payload()))
+
+		_, err = provider.Get("other")
+		assert.Error(t, err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+	dir := t.TempDir()
+	fn := filepath.Join(dir, "secrets.toml")
+	provider, err := Create(dir, "encrypted", map[string]interface{}{"encrypt": true, "passphrase-env": ""})
+	assert.NoError(t, err)
+
+	// Without a prompter there is no way to get the passphrase.
+	store, err := provider.Open()
+	assert.NoError(t, err)
+	assert.NoError(t, store.Add("api", map[string]interface{}{"key": "secret"}))
+	assert.Error(t, store.Save())
+
+	// New files must have the passphrase confirmed.
+	provider.SetPrompter(&prompterMock{answers: []string{"passphrase", "other"}})
+	assert.Error(t, store.Save())
+
+	provider.SetPrompter(&prompterMock{answers: []string{"passphrase", "passphrase"}})
+	assert.NoError(t, store.Save())
+
+	raw, err := os.ReadFile(fn)
+	assert.NoError(t, err)
+	assert.True(t, crypt.IsSealed(raw))
+	assert.NotContains(t, string(raw), "secret")
+
+	// The passphrase entered is remembered.
+	profile, err := provider.Get("api")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"key":"secret"}`, string(profile.Payload()))
+
+	// A wrong passphrase is forgotten so the user can try again.
+	provider, err = Create(dir, "encrypted", map[string]interface{}{"passphrase-env": ""})
+	assert.NoError(t, err)
+	prompter := &prompterMock{answers: []string{"wrong", "passphrase"}}
+	provider.SetPrompter(prompter)
+
+	_, err = provider.Get("api")
+	assert.Error(t, err)
+	_, err = provider.Get("api")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, prompter.prompts)
+
+	// Files already encrypted stays encrypted even if encrypt isn't set.
+	store, err = provider.Open()
+	assert.NoError(t, err)
+	assert.NoError(t, store.Rotate("api", map[string]interface{}{"key": "rotated"}))
+	assert.NoError(t, store.Save())
+	raw, err = os.ReadFile(fn)
+	assert.NoError(t, err)
+	assert.True(t, crypt.IsSealed(raw))
+
+	// Passphrase can be read from file.
+	passFile := filepath.Join(dir, "passphrase")
+	assert.NoError(t, os.WriteFile(passFile, []byte("passphrase\n"), 0600))
+	provider, err = Create(dir, "encrypted", map[string]interface{}{"passphrase-env": "", "passphrase-file": passFile})
+	assert.NoError(t, err)
+	profile, err = provider.Get("api")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"key":"rotated"}`, string(profile.Payload()))
+
+	// Passphrase can be read from env.
+	os.Setenv("PM_CREDS_TEST_PASSPHRASE", "passphrase")
+	defer os.Unsetenv("PM_CREDS_TEST_PASSPHRASE")
+	provider, err = Create(dir, "encrypted", map[string]interface{}{"passphrase-env": "PM_CREDS_TEST_PASSPHRASE"})
+	assert.NoError(t, err)
+	_, err = provider.Get("api")
+	assert.NoError(t, err)
+}
@@ -2,6 +2,11 @@
 // provider that can be used by the providers package.
 package types
 
+import (
+	"context"
+	"time"
+)
+
 type Provider interface {
 	Name() string
 	Get(name string) (Profile, error)
@@ -10,4 +15,71 @@ type Provider interface {
 type Profile interface {
 	Name() string
 	Payload() []byte
+	Metadata() Metadata
+}
+
+// Kinds of credentials a profile can contain.
+const (
+	KindStatic  = "static"
+	KindSession = "session"
+	KindSecret  = "secret"
+)
+
+// Metadata describes the credentials of a profile.
+type Metadata struct {
+	// Expires is when the credentials expires. Zero if they don't expire.
+	Expires time.Time
+	// Source describes where the credentials were retrieved from.
+	Source string
+	// Kind is the kind of credentials, for example KindStatic.
+	Kind string
+}
+
+// ContextGetter can be satisfied by providers that can use the context of the request
+// for credentials, for example so that prompts are cancelled when the client disconnects.
+type ContextGetter interface {
+	GetContext(ctx context.Context, name string) (Profile, error)
+}
+
+// Refresher can be satisfied by providers that could need the user to interact when
+// getting a profile. Refresh gets profile name without any interaction and is used
+// when cached profiles are refreshed in the background.
+type Refresher interface {
+	Refresh(name string) (Profile, error)
+}
+
+// FileReader can be satisfied by providers that reads credentials from files.
+// Cached profiles are invalidated when any of the files changes.
+type FileReader interface {
+	Files() []string
+}
+
+// Prompter is used by providers to ask the user for input, for example mfa
+// tokens. It's satisfied by the server so the same console is used as for approvals.
+// The prompt is cancelled when ctx is done.
+type Prompter interface {
+	Prompt(ctx context.Context, format string, a ...interface{}) (string, error)
+	// PromptSecret asks for input that must not be echoed, for example passphrases.
+	PromptSecret(ctx context.Context, format string, a ...interface{}) (string, error)
+}
+
+// PromptSetter can be satisfied by providers that needs to ask the user for input.
+type PromptSetter interface {
+	SetPrompter(prompter Prompter)
+}
+
+// Authorizer is used by providers to let the user authorize in a browser and
+// receive the authorization code on the callback route of the server.
+type Authorizer interface {
+	// CallbackURL returns the url the authorization server should redirect to.
+	CallbackURL() string
+	// Authorize will show authURL to the user and wait for the callback with state.
+	// Returns the authorization code.
+	Authorize(authURL string, state string) (string, error)
+}
+
+// AuthorizerSetter can be satisfied by providers that needs the user to authorize in a browser.
+// SetAuthorizer returns true if the provider has profiles that uses the authorizer.
+type AuthorizerSetter interface {
+	SetAuthorizer(authorizer Authorizer) bool
 }
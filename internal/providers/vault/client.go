@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// errPermissionDenied is returned when vault responds with 403.
+var errPermissionDenied = errors.New("permission denied")
+
+// response is the generic response returned by the vault api.
+type response struct {
+	LeaseDuration int             `json:"lease_duration"`
+	Renewable     bool            `json:"renewable"`
+	Data          json.RawMessage `json:"data"`
+	Auth          *struct {
+		ClientToken   string `json:"client_token"`
+		LeaseDuration int    `json:"lease_duration"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// call will make a request authenticated with the vault token. If the request is
+// denied and the token is from an approle login, a new login is made and the request retried.
+func (p *Provider) call(method string, path string, body interface{}, resp *response) error {
+	token, err := p.getToken()
+	if err != nil {
+		return err
+	}
+
+	err = p.request(method, path, token, body, resp)
+	if errors.Is(err, errPermissionDenied) && p.appRoleID != "" && p.token == "" && p.tokenFile == "" {
+		p.resetAppRoleToken()
+		if token, err = p.getToken(); err != nil {
+			return err
+		}
+		err = p.request(method, path, token, body, resp)
+	}
+
+	return err
+}
+
+// request will make a request to path of the vault api and json unmarshal the response into resp.
+func (p *Provider) request(method string, path string, token string, body interface{}, resp *response) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("couldn't json marshal request body. %w", err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s", p.address, strings.TrimPrefix(path, "/"))
+	req, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return fmt.Errorf("couldn't create request to %q. %w", url, err)
+	}
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if p.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", p.namespace)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	res, err := p.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("couldn't make request to %q. %w", url, err)
+	}
+	defer res.Body.Close()
+
+	raw, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("couldn't read response from %q. %w", url, err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		msg := res.Status
+		vaultErr := &response{}
+		if json.Unmarshal(raw, vaultErr) == nil && len(vaultErr.Errors) > 0 {
+			msg = strings.Join(vaultErr.Errors, ", ")
+		}
+		if res.StatusCode == http.StatusForbidden {
+			return fmt.Errorf("request to %q failed. %w: %s", url, errPermissionDenied, msg)
+		}
+		return fmt.Errorf("request to %q failed. %s", url, msg)
+	}
+
+	if err := json.Unmarshal(raw, resp); err != nil {
+		return fmt.Errorf("couldn't json unmarshal response from %q. %w", url, err)
+	}
+
+	return nil
+}
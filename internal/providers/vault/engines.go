@@ -0,0 +1,103 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// kvSecret will read the latest version of the secret at the path of cfg from the kv version 2 engine.
+func (p *Provider) kvSecret(name string, cfg *profile) ([]byte, types.Metadata, error) {
+	mount := cfg.Mount
+	if mount == "" {
+		mount = p.kvMount
+	}
+	path := cfg.Path
+	if path == "" {
+		path = name
+	}
+	path = fmt.Sprintf("%s/data/%s", strings.Trim(mount, "/"), strings.TrimPrefix(path, "/"))
+
+	resp := &response{}
+	if err := p.call(http.MethodGet, path, nil, resp); err != nil {
+		return nil, types.Metadata{}, err
+	}
+
+	data := &struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.Unmarshal(resp.Data, data); err != nil {
+		return nil, types.Metadata{}, fmt.Errorf("couldn't json unmarshal secret %q. %w", path, err)
+	}
+	if data.Data == nil {
+		return nil, types.Metadata{}, fmt.Errorf("secret %q has no data. deleted?", path)
+	}
+
+	payload, err := marshal(data.Data)
+	if err != nil {
+		return nil, types.Metadata{}, err
+	}
+
+	return payload, types.Metadata{Source: fmt.Sprintf("vault: %s", path), Kind: types.KindSecret}, nil
+}
+
+// awsCredentials will request credentials for the role of cfg from the aws engine.
+// Returns the payload in the same format as the aws provider with the lease expiry.
+func (p *Provider) awsCredentials(name string, cfg *profile) ([]byte, types.Metadata, error) {
+	mount := cfg.Mount
+	if mount == "" {
+		mount = p.awsMount
+	}
+	role := cfg.Role
+	if role == "" {
+		role = name
+	}
+	path := fmt.Sprintf("%s/%s/%s", strings.Trim(mount, "/"), cfg.Type, role)
+
+	body := map[string]string{}
+	if cfg.RoleARN != "" {
+		body["role_arn"] = cfg.RoleARN
+	}
+	if cfg.TTL != "" {
+		body["ttl"] = cfg.TTL
+	}
+
+	resp := &response{}
+	if err := p.call(http.MethodPost, path, body, resp); err != nil {
+		return nil, types.Metadata{}, err
+	}
+
+	creds := &struct {
+		AccessKey     string `json:"access_key"`
+		SecretKey     string `json:"secret_key"`
+		SecurityToken string `json:"security_token"`
+	}{}
+	if err := json.Unmarshal(resp.Data, creds); err != nil {
+		return nil, types.Metadata{}, fmt.Errorf("couldn't json unmarshal credentials %q. %w", path, err)
+	}
+	if creds.AccessKey == "" || creds.SecretKey == "" {
+		return nil, types.Metadata{}, fmt.Errorf("credentials %q has no access or secret key", path)
+	}
+
+	metadata := types.Metadata{Source: fmt.Sprintf("vault: %s", path), Kind: types.KindStatic}
+	fields := map[string]interface{}{"accessKey": creds.AccessKey, "secretKey": creds.SecretKey}
+	if creds.SecurityToken != "" {
+		fields["sessionToken"] = creds.SecurityToken
+		metadata.Kind = types.KindSession
+	}
+	if resp.LeaseDuration > 0 {
+		metadata.Expires = time.Now().Add(time.Duration(resp.LeaseDuration) * time.Second).UTC()
+		fields["expiration"] = metadata.Expires.Format(time.RFC3339)
+	}
+
+	payload, err := marshal(fields)
+	if err != nil {
+		return nil, types.Metadata{}, err
+	}
+
+	return payload, metadata, nil
+}
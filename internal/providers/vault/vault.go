@@ -0,0 +1,323 @@
+// Package vault is a provider that can be used by the providers package to
+// retrieve secrets from the kv version 2 and aws secrets engines in HashiCorp Vault.
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+// Secrets engines supported.
+const (
+	EngineKV  = "kv"
+	EngineAWS = "aws"
+)
+
+// Defaults used if not configured.
+const (
+	defaultKVMount      = "secret"
+	defaultAWSMount     = "aws"
+	defaultAppRoleMount = "approle"
+	defaultTimeout      = 30 * time.Second
+)
+
+// tokenWindow is how long before expiry an approle token is renewed.
+const tokenWindow = time.Minute
+
+// profile is the configuration of a single profile.
+type profile struct {
+	Engine  string `mapstructure:"engine"`
+	Path    string `mapstructure:"path"`
+	Mount   string `mapstructure:"mount"`
+	Role    string `mapstructure:"role"`
+	Type    string `mapstructure:"credential-type"`
+	RoleARN string `mapstructure:"role-arn"`
+	TTL     string `mapstructure:"ttl"`
+}
+
+// Create will create a new provider with name based on config and return it.
+func Create(name string, raw map[string]interface{}) (*Provider, error) {
+	data := &struct {
+		Address         string              `mapstructure:"address"`
+		Namespace       string              `mapstructure:"namespace"`
+		Token           string              `mapstructure:"token"`
+		TokenFile       string              `mapstructure:"token-file"`
+		AppRoleMount    string              `mapstructure:"approle-mount"`
+		AppRoleID       string              `mapstructure:"approle-role-id"`
+		AppRoleSecret   string              `mapstructure:"approle-secret-id"`
+		AppRoleSecretFn string              `mapstructure:"approle-secret-id-file"`
+		KVMount         string              `mapstructure:"kv-mount"`
+		AWSMount        string              `mapstructure:"aws-mount"`
+		Timeout         string              `mapstructure:"timeout"`
+		Profiles        map[string]*profile `mapstructure:"profiles"`
+	}{
+		Address:      os.Getenv("VAULT_ADDR"),
+		Namespace:    os.Getenv("VAULT_NAMESPACE"),
+		AppRoleMount: defaultAppRoleMount,
+		KVMount:      defaultKVMount,
+		AWSMount:     defaultAWSMount,
+	}
+	if err := mapstructure.Decode(raw, data); err != nil {
+		return nil, fmt.Errorf("vault: couldn't decode raw to data for %q. %w", name, err)
+	}
+
+	if data.Address == "" {
+		return nil, fmt.Errorf("vault: no %s set for %q", "address", name)
+	}
+
+	if data.AppRoleID != "" && data.AppRoleSecret == "" && data.AppRoleSecretFn == "" {
+		return nil, fmt.Errorf("vault: no %s set for %q", "approle-secret-id", name)
+	}
+
+	timeout := defaultTimeout
+	if data.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(data.Timeout); err != nil {
+			return nil, fmt.Errorf("vault: couldn't parse %s for %q. %w", "timeout", name, err)
+		}
+	}
+
+	for profileName, cfg := range data.Profiles {
+		cfg.Engine = strings.ToLower(cfg.Engine)
+		if cfg.Engine == "" {
+			cfg.Engine = EngineKV
+		}
+		if cfg.Engine != EngineKV && cfg.Engine != EngineAWS {
+			return nil, fmt.Errorf("vault: invalid %s %q in profile %q for %q", "engine", cfg.Engine, profileName, name)
+		}
+		if cfg.Type == "" {
+			cfg.Type = "creds"
+		}
+		if cfg.Type != "creds" && cfg.Type != "sts" {
+			return nil, fmt.Errorf("vault: invalid %s %q in profile %q for %q", "credential-type", cfg.Type, profileName, name)
+		}
+	}
+
+	return &Provider{
+		name:            name,
+		address:         strings.TrimSuffix(data.Address, "/"),
+		namespace:       data.Namespace,
+		token:           data.Token,
+		tokenFile:       data.TokenFile,
+		appRoleMount:    data.AppRoleMount,
+		appRoleID:       data.AppRoleID,
+		appRoleSecret:   data.AppRoleSecret,
+		appRoleSecretFn: data.AppRoleSecretFn,
+		kvMount:         data.KVMount,
+		awsMount:        data.AWSMount,
+		profiles:        data.Profiles,
+		client:          &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Provider satisfies the types.Provider interface and can be used as
+// a provider by the providers package.
+type Provider struct {
+	name string
+
+	address         string
+	namespace       string
+	token           string
+	tokenFile       string
+	appRoleMount    string
+	appRoleID       string
+	appRoleSecret   string
+	appRoleSecretFn string
+	kvMount         string
+	awsMount        string
+	profiles        map[string]*profile
+	client          *http.Client
+
+	mu           sync.Mutex
+	loginToken   string
+	loginExpires time.Time
+}
+
+// Name returns the provider name.
+func (p *Provider) Name() string {
+	return p.name
+}
+
+// Get will retrieve profile name from vault. Profiles not configured are read
+// from the kv engine with the profile name as path.
+func (p *Provider) Get(name string) (types.Profile, error) {
+	cfg, ok := p.profiles[name]
+	if !ok {
+		if err := validPath(name); err != nil {
+			return nil, fmt.Errorf("vault: invalid profile %q for %q. %w", name, p.Name(), err)
+		}
+		cfg = &profile{Engine: EngineKV, Path: name}
+	}
+
+	var payload []byte
+	var metadata types.Metadata
+	var err error
+
+	switch cfg.Engine {
+	case EngineAWS:
+		payload, metadata, err = p.awsCredentials(name, cfg)
+	default:
+		payload, metadata, err = p.kvSecret(name, cfg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("vault: couldn't get secret for %q from %q. %w", name, p.Name(), err)
+	}
+
+	return &Profile{name: name, payload: payload, metadata: metadata}, nil
+}
+
+// validPath returns an error if name can't be used as a path in the kv engine. Every
+// segment must be set and can't be "." or "..", and control characters aren't allowed.
+func validPath(name string) error {
+	for _, segment := range strings.Split(name, "/") {
+		switch segment {
+		case "", ".", "..":
+			return fmt.Errorf("profile can't contain the path segment %q", segment)
+		}
+	}
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return fmt.Errorf("profile can't contain control characters")
+		}
+	}
+	return nil
+}
+
+// Files returns the token files used to authenticate.
+func (p *Provider) Files() []string {
+	files := []string{}
+	for _, fn := range []string{p.tokenFile, p.appRoleSecretFn} {
+		if fn != "" {
+			files = append(files, fn)
+		}
+	}
+	return files
+}
+
+// getToken returns the token used to authenticate. The token is taken from token,
+// token-file, an approle login, VAULT_TOKEN or ~/.vault-token in that order.
+func (p *Provider) getToken() (string, error) {
+	if p.token != "" {
+		return p.token, nil
+	}
+
+	if p.tokenFile != "" {
+		return readToken(p.tokenFile)
+	}
+
+	if p.appRoleID != "" {
+		return p.appRoleToken()
+	}
+
+	if token := os.Getenv("VAULT_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("no token set. %w", err)
+	}
+	token, err := readToken(filepath.Join(home, ".vault-token"))
+	if err != nil {
+		return "", fmt.Errorf("no token set. %w", err)
+	}
+	return token, nil
+}
+
+// readToken returns the token stored in file fn.
+func readToken(fn string) (string, error) {
+	raw, err := os.ReadFile(fn)
+	if err != nil {
+		return "", fmt.Errorf("couldn't read token file %q. %w", fn, err)
+	}
+
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		return "", fmt.Errorf("token file %q is empty", fn)
+	}
+	return token, nil
+}
+
+// appRoleToken returns the token from the last approle login or logins if it has expired.
+func (p *Provider) appRoleToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.loginToken != "" && (p.loginExpires.IsZero() || time.Now().Add(tokenWindow).Before(p.loginExpires)) {
+		return p.loginToken, nil
+	}
+
+	secretID := p.appRoleSecret
+	if p.appRoleSecretFn != "" {
+		var err error
+		if secretID, err = readToken(p.appRoleSecretFn); err != nil {
+			return "", err
+		}
+	}
+
+	body := map[string]string{"role_id": p.appRoleID, "secret_id": secretID}
+	resp := &response{}
+	if err := p.request(http.MethodPost, fmt.Sprintf("auth/%s/login", p.appRoleMount), "", body, resp); err != nil {
+		return "", fmt.Errorf("couldn't login with approle. %w", err)
+	}
+	if resp.Auth == nil || resp.Auth.ClientToken == "" {
+		return "", fmt.Errorf("couldn't login with approle. no token returned")
+	}
+
+	p.loginToken = resp.Auth.ClientToken
+	p.loginExpires = time.Time{}
+	if resp.Auth.LeaseDuration > 0 {
+		p.loginExpires = time.Now().Add(time.Duration(resp.Auth.LeaseDuration) * time.Second)
+	}
+
+	return p.loginToken, nil
+}
+
+// resetAppRoleToken will forget the approle token so that a new login is made.
+func (p *Provider) resetAppRoleToken() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.loginToken = ""
+}
+
+// Profile satisfies the types.Profile interface and can be used
+// as a profile by the providers package.
+type Profile struct {
+	name     string
+	payload  []byte
+	metadata types.Metadata
+}
+
+// Name returns the profile name.
+func (p *Profile) Name() string {
+	return p.name
+}
+
+// Payload returns the profile json payload.
+func (p *Profile) Payload() []byte {
+	return p.payload
+}
+
+// Metadata returns the profile metadata.
+func (p *Profile) Metadata() types.Metadata {
+	return p.metadata
+}
+
+// marshal will json marshal v.
+func marshal(v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't json marshal payload. %w", err)
+	}
+	return payload, nil
+}
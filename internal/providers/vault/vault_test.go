@@ -0,0 +1,201 @@
+package vault
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// vaultStub is a minimal stand-in for the vault api.
+type vaultStub struct {
+	tokens map[string]bool
+	logins int
+	body   map[string]string
+}
+
+func (v *vaultStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(code int, body string) {
+		w.WriteHeader(code)
+		fmt.Fprint(w, body)
+	}
+
+	if r.URL.Path == "/v1/auth/approle/login" {
+		body := map[string]string{}
+		json.NewDecoder(r.Body).Decode(&body)
+		if body["role_id"] != "role-id" || body["secret_id"] != "secret-id" {
+			reply(http.StatusBadRequest, `{"errors":["invalid role or secret ID"]}`)
+			return
+		}
+		v.logins++
+		token := fmt.Sprintf("approle-token-%d", v.logins)
+		v.tokens[token] = true
+		reply(http.StatusOK, fmt.Sprintf(`{"auth":{"client_token":%q,"lease_duration":3600}}`, token))
+		return
+	}
+
+	if !v.tokens[r.Header.Get("X-Vault-Token")] {
+		reply(http.StatusForbidden, `{"errors":["permission denied"]}`)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v1/secret/data/github", "/v1/team/data/apps/api":
+		reply(http.StatusOK, `{"data":{"data":{"token":"secret"},"metadata":{"version":1}}}`)
+	case "/v1/secret/data/deleted":
+		reply(http.StatusOK, `{"data":{"data":null,"metadata":{"version":2}}}`)
+	case "/v1/aws/creds/deploy":
+		reply(http.StatusOK, `{"lease_duration":0,"data":{"access_key":"AKIA","secret_key":"secret"}}`)
+	case "/v1/aws/sts/deploy":
+		v.body = map[string]string{}
+		json.NewDecoder(r.Body).Decode(&v.body)
+		reply(http.StatusOK, `{"lease_duration":900,"data":{"access_key":"ASIA","secret_key":"secret","security_token":"token"}}`)
+	default:
+		reply(http.StatusNotFound, `{"errors":[]}`)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	stub := &vaultStub{tokens: map[string]bool{"root": true}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, err := Create("vault", map[string]interface{}{
+		"address":   server.URL,
+		"token":     "root",
+		"namespace": "team",
+		"profiles": map[string]interface{}{
+			"api":     map[string]interface{}{"mount": "team", "path": "apps/api"},
+			"deploy":  map[string]interface{}{"engine": "aws"},
+			"session": map[string]interface{}{"engine": "AWS", "role": "deploy", "credential-type": "sts", "ttl": "15m"},
+		},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "vault", provider.Name())
+
+	for name, payload := range map[string]string{
+		"github": `{"token":"secret"}`,
+		"api":    `{"token":"secret"}`,
+		"deploy": `{"accessKey":"AKIA","secretKey":"secret"}`,
+	} {
+		profile, err := provider.Get(name)
+		assert.NoError(t, err)
+		assert.Equal(t, name, profile.Name())
+		assert.JSONEq(t, payload, string(profile.Payload()))
+	}
+
+	profile, err := provider.Get("session")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ttl": "15m"}, stub.body)
+	assert.Equal(t, types.KindSession, profile.Metadata().Kind)
+	assert.WithinDuration(t, time.Now().Add(15*time.Minute), profile.Metadata().Expires, time.Minute)
+
+	fields := map[string]string{}
+	assert.NoError(t, json.Unmarshal(profile.Payload(), &fields))
+	assert.Equal(t, "token", fields["sessionToken"])
+	assert.Equal(t, profile.Metadata().Expires.Format(time.RFC3339), fields["expiration"])
+
+	for _, name := range []string{"deleted", "notexist"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err)
+	}
+
+	// Profiles not configured must be a clean path in the kv engine.
+	for _, name := range []string{"", "/github", "apps//api", "../sys/policy", "apps/./api", "apps/..", "api\n"} {
+		_, err := provider.Get(name)
+		assert.Error(t, err, name)
+		if err != nil {
+			assert.Contains(t, err.Error(), "invalid profile", name)
+		}
+	}
+}
+
+func TestTokens(t *testing.T) {
+	stub := &vaultStub{tokens: map[string]bool{"file-token": true, "env-token": true}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	// Token from file.
+	fn := filepath.Join(t.TempDir(), "token")
+	assert.NoError(t, os.WriteFile(fn, []byte("file-token\n"), 0600))
+	provider, err := Create("vault", map[string]interface{}{"address": server.URL, "token-file": fn})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{fn}, provider.Files())
+	_, err = provider.Get("github")
+	assert.NoError(t, err)
+
+	// Token from env.
+	os.Setenv("VAULT_TOKEN", "env-token")
+	defer os.Unsetenv("VAULT_TOKEN")
+	provider, err = Create("vault", map[string]interface{}{"address": server.URL})
+	assert.NoError(t, err)
+	_, err = provider.Get("github")
+	assert.NoError(t, err)
+
+	// Wrong token.
+	provider, err = Create("vault", map[string]interface{}{"address": server.URL, "token": "wrong"})
+	assert.NoError(t, err)
+	_, err = provider.Get("github")
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "permission denied"))
+}
+
+func TestAppRole(t *testing.T) {
+	stub := &vaultStub{tokens: map[string]bool{}}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	provider, err := Create("vault", map[string]interface{}{
+		"address":           server.URL,
+		"approle-role-id":   "role-id",
+		"approle-secret-id": "secret-id",
+	})
+	assert.NoError(t, err)
+
+	// Token is reused between requests.
+	for i := 0; i < 2; i++ {
+		_, err = provider.Get("github")
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 1, stub.logins)
+
+	// A revoked token results in a new login.
+	stub.tokens = map[string]bool{}
+	_, err = provider.Get("github")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, stub.logins)
+
+	provider, err = Create("vault", map[string]interface{}{
+		"address":           server.URL,
+		"approle-role-id":   "role-id",
+		"approle-secret-id": "wrong",
+	})
+	assert.NoError(t, err)
+	_, err = provider.Get("github")
+	assert.Error(t, err)
+	assert.True(t, strings.Contains(err.Error(), "invalid role or secret ID"))
+}
+
+func TestCreateErrors(t *testing.T) {
+	os.Unsetenv("VAULT_ADDR")
+
+	for _, config := range []map[string]interface{}{
+		{},
+		{"address": "http://localhost:8200", "approle-role-id": "role-id"},
+		{"address": "http://localhost:8200", "timeout": "forever"},
+		{"address": "http://localhost:8200", "profiles": map[string]interface{}{"x": map[string]interface{}{"engine": "database"}}},
+		{"address": "http://localhost:8200", "profiles": map[string]interface{}{"x": map[string]interface{}{"engine": "aws", "credential-type": "iam_user"}}},
+		{"address": []string{"http://localhost:8200"}},
+	} {
+		_, err := Create("vault", config)
+		assert.Error(t, err, config)
+	}
+}
@@ -0,0 +1,206 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"text/template"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// Approvers supported.
+const (
+	ApproverConsole = "console"
+	ApproverWeb     = "web"
+	ApproverCommand = "command"
+	ApproverNotify  = "notify"
+)
+
+// Approver asks the user to approve a request. It's called by the approval queue for
+// one request at a time, waiting is the number of requests left in the queue.
+type Approver interface {
+	// Approve returns the answer in the same format as the console prompt, "y", "n"
+	// or "y<duration>". It must return the error of ctx if it's done before the answer.
+	Approve(ctx context.Context, req *Request, waiting int) (string, error)
+}
+
+// defaultNotifyCommand shows a desktop notification with approve and deny actions and
+// prints the action chosen.
+var defaultNotifyCommand = []string{
+	"notify-send", "--app-name=pm-creds", "--urgency={{.Urgency}}", "--wait",
+	"--action=y=Approve", "--action=n=Deny", "pm-creds", "{{.Message}}",
+}
+
+// newApprover returns the approver of type kind. command is the command used by the
+// command and notify approvers.
+func newApprover(kind string, command []string, cfg *config) (Approver, error) {
+	switch strings.ToLower(kind) {
+	case "", ApproverConsole:
+		return &consoleApprover{logger: cfg.logger}, nil
+
+	case ApproverWeb:
+		return newWebApprover(cfg.logger, fmt.Sprintf("https://%s/approvals", fmt.Sprintf(listen, cfg.CallbackPort)))
+
+	case ApproverCommand:
+		if len(command) == 0 {
+			return nil, fmt.Errorf("no %s set for approver %q", "approver-command", kind)
+		}
+		args, err := parseArgs(command)
+		if err != nil {
+			return nil, err
+		}
+		return &commandApprover{args: args}, nil
+
+	case ApproverNotify:
+		if len(command) == 0 {
+			command = defaultNotifyCommand
+		}
+		args, err := parseArgs(command)
+		if err != nil {
+			return nil, err
+		}
+		return &notifyApprover{args: args, logger: cfg.logger}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid %s %q", "approver", kind)
+	}
+}
+
+// message returns the question asked for req.
+func message(req *Request) string {
+	return fmt.Sprintf("authorize credentials for %q (%s) %s?", req.ProfileName, req.ProviderName, req.Remote)
+}
+
+// consoleApprover asks for approval in the console.
+type consoleApprover struct {
+	logger *logging.Logger
+}
+
+// Approve will show the prompt in the console and read the answer.
+func (a *consoleApprover) Approve(ctx context.Context, req *Request, waiting int) (string, error) {
+	consoleMu.Lock()
+	defer consoleMu.Unlock()
+
+	console.drain()
+	prompt := fmt.Sprintf("%s [y/n/y<duration>]: ", message(req))
+	if waiting > 0 {
+		prompt = fmt.Sprintf("(%d more in queue) %s", waiting, prompt)
+	}
+	switch req.Warn {
+	case true:
+		a.logger.Alert(prompt)
+	case false:
+		a.logger.Warning(prompt)
+	}
+
+	return console.read(ctx)
+}
+
+// commandApprover runs a command that approves the request if it exits with
+// status 0. It can print an answer, for example "y15m", to approve for a duration.
+// Commands that can't be run returns an error instead of denying the request.
+type commandApprover struct {
+	args []*template.Template
+}
+
+// Approve will run the command and return its answer.
+func (a *commandApprover) Approve(ctx context.Context, req *Request, waiting int) (string, error) {
+	out, err := runApprover(ctx, a.args, req)
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case errors.As(err, &exitErr):
+		return "n", nil
+	case err != nil:
+		return "", err
+	}
+
+	// Only answers that approves are used, anything else printed is ignored.
+	answer := strings.TrimSpace(string(out))
+	if approved, _, err := parseAnswer(answer, 0); approved && err == nil {
+		return answer, nil
+	}
+	return "y", nil
+}
+
+// notifyApprover shows a desktop notification using a command, notify-send by default,
+// and uses what it prints as the answer.
+type notifyApprover struct {
+	args   []*template.Template
+	logger *logging.Logger
+}
+
+// Approve will show the notification and return the action chosen. Dismissed
+// notifications are denied.
+func (a *notifyApprover) Approve(ctx context.Context, req *Request, waiting int) (string, error) {
+	a.logger.Print("waiting for approval of %q (%s) %s in notification%s", req.ProfileName, req.ProviderName, req.Remote, logging.Lb())
+
+	out, err := runApprover(ctx, a.args, req)
+	switch {
+	case ctx.Err() != nil:
+		return "", ctx.Err()
+	case err != nil:
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// parseArgs parses the command arguments as templates.
+func parseArgs(command []string) ([]*template.Template, error) {
+	args := []*template.Template{}
+	for _, arg := range command {
+		tmpl, err := template.New("approver").Option("missingkey=error").Parse(arg)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse approver command %q. %w", arg, err)
+		}
+		args = append(args, tmpl)
+	}
+	return args, nil
+}
+
+// runApprover will run the command args for req and return what it wrote to stdout.
+// The request is available in the arguments and as environmental variables.
+func runApprover(ctx context.Context, args []*template.Template, req *Request) ([]byte, error) {
+	urgency := "normal"
+	if req.Warn {
+		urgency = "critical"
+	}
+	vars := map[string]string{
+		"Provider": req.ProviderName,
+		"Profile":  req.ProfileName,
+		"Client":   req.Client,
+		"Remote":   req.Remote,
+		"Message":  message(req),
+		"Urgency":  urgency,
+	}
+
+	cmd := &process.Command{
+		Context: ctx,
+		Env: []string{
+			"PM_CREDS_PROVIDER=" + req.ProviderName,
+			"PM_CREDS_PROFILE=" + req.ProfileName,
+			"PM_CREDS_CLIENT=" + req.Client,
+			"PM_CREDS_REMOTE=" + req.Remote,
+			fmt.Sprintf("PM_CREDS_WARN=%t", req.Warn),
+		},
+	}
+	for _, arg := range args {
+		buf := &bytes.Buffer{}
+		if err := arg.Execute(buf, vars); err != nil {
+			return nil, fmt.Errorf("couldn't create approver command. %w", err)
+		}
+		cmd.Args = append(cmd.Args, buf.String())
+	}
+
+	out, err := cmd.Run()
+	if err != nil && errors.Is(err, context.Canceled) {
+		return nil, ctx.Err()
+	}
+	return out, err
+}
@@ -0,0 +1,175 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/process"
+	"github.com/stretchr/testify/assert"
+)
+
+func testRequest() *Request {
+	return &Request{ProviderName: "aws", ProfileName: "dev", Client: "client", Remote: "remote", Warn: true}
+}
+
+func TestNewApprover(t *testing.T) {
+	cfg := &config{Port: 9999, logger: logging.New()}
+
+	for kind, expected := range map[string]interface{}{
+		"":        &consoleApprover{},
+		"Console": &consoleApprover{},
+		"web":     &webApprover{},
+		"command": &commandApprover{},
+		"notify":  &notifyApprover{},
+	} {
+		approver, err := newApprover(kind, []string{"true"}, cfg)
+		assert.NoError(t, err, kind)
+		assert.IsType(t, expected, approver, kind)
+	}
+
+	_, err := newApprover("command", nil, cfg)
+	assert.EqualError(t, err, `no approver-command set for approver "command"`)
+	_, err = newApprover("command", []string{"{{.Provider"}, cfg)
+	assert.Error(t, err)
+	_, err = newApprover("email", nil, cfg)
+	assert.EqualError(t, err, `invalid approver "email"`)
+
+	// notify-send is used by default.
+	approver, err := newApprover("notify", nil, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, approver.(*notifyApprover).args, len(defaultNotifyCommand))
+}
+
+func TestCommandApprover(t *testing.T) {
+	for line, expected := range map[string]string{
+		`test "$PM_CREDS_PROFILE" = dev`:            "y",
+		`test "$PM_CREDS_PROFILE" = prod`:           "n",
+		`test "$PM_CREDS_WARN" = true && echo y15m`: "y15m",
+		`echo approved`:                             "y",
+		`echo yes`:                                  "y",
+		`echo "y please"`:                           "y",
+		`echo n`:                                    "y",
+	} {
+		args, err := parseArgs(process.Shell(line))
+		assert.NoError(t, err)
+		answer, err := (&commandApprover{args: args}).Approve(context.Background(), testRequest(), 0)
+		assert.NoError(t, err, line)
+		assert.Equal(t, expected, answer, line)
+	}
+
+	// The request is available in the arguments.
+	args, err := parseArgs([]string{"echo", "y{{.Profile}}"})
+	assert.NoError(t, err)
+	answer, err := (&commandApprover{args: args}).Approve(context.Background(), &Request{ProfileName: "1m"}, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, "y1m", answer)
+
+	// Commands that can't be run are errors instead of denials.
+	for _, command := range [][]string{{"pm-creds-missing-command"}, {"echo", "{{.Missing}}"}} {
+		args, err := parseArgs(command)
+		assert.NoError(t, err)
+		answer, err := (&commandApprover{args: args}).Approve(context.Background(), testRequest(), 0)
+		assert.Error(t, err, command)
+		assert.Empty(t, answer, command)
+	}
+
+	// The command is killed when ctx is done.
+	args, err = parseArgs([]string{"sleep", "10"})
+	assert.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = (&commandApprover{args: args}).Approve(ctx, testRequest(), 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestNotifyApprover(t *testing.T) {
+	for line, expected := range map[string]string{
+		`echo y`: "y",
+		`echo n`: "n",
+		`true`:   "",
+	} {
+		args, err := parseArgs(process.Shell(line))
+		assert.NoError(t, err)
+		answer, err := (&notifyApprover{args: args, logger: logging.New()}).Approve(context.Background(), testRequest(), 0)
+		assert.NoError(t, err, line)
+		assert.Equal(t, expected, answer, line)
+	}
+
+	args, err := parseArgs([]string{"echo", "{{.Urgency}}", "{{.Message}}"})
+	assert.NoError(t, err)
+	answer, err := (&notifyApprover{args: args, logger: logging.New()}).Approve(context.Background(), testRequest(), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, `critical authorize credentials for "dev" (aws) remote?`, answer)
+
+	args, err = parseArgs([]string{"false"})
+	assert.NoError(t, err)
+	_, err = (&notifyApprover{args: args, logger: logging.New()}).Approve(context.Background(), testRequest(), 0)
+	assert.Error(t, err)
+}
+
+func TestWebApprover(t *testing.T) {
+	approver, err := newWebApprover(logging.New(), "https://localhost:9999/approvals")
+	assert.NoError(t, err)
+
+	// The page requires the token.
+	w := httptest.NewRecorder()
+	approver.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/approvals", nil))
+	assert.Equal(t, 401, w.Code)
+
+	answers := make(chan string)
+	go func() {
+		answer, err := approver.Approve(context.Background(), testRequest(), 0)
+		assert.NoError(t, err)
+		answers <- answer
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	w = httptest.NewRecorder()
+	approver.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/approvals?token="+approver.token, nil))
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "authorize credentials for &#34;dev&#34; (aws) remote?")
+	id := regexp.MustCompile(`name="id" value="([0-9a-f]+)"`).FindStringSubmatch(w.Body.String())[1]
+
+	post := func(form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/approvals", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		approver.ServeHTTP(w, r)
+		return w
+	}
+
+	// Answers without the token in the form are rejected.
+	assert.Equal(t, 401, post(url.Values{"id": {id}, "answer": {"y"}}).Code)
+	assert.Equal(t, 400, post(url.Values{"token": {approver.token}, "id": {id}, "answer": {"duration"}, "duration": {"soon"}}).Code)
+	assert.Equal(t, 303, post(url.Values{"token": {approver.token}, "id": {id}, "answer": {"duration"}, "duration": {"15m"}}).Code)
+	assert.Equal(t, "y15m", <-answers)
+	assert.Equal(t, 400, post(url.Values{"token": {approver.token}, "id": {id}, "answer": {"y"}}).Code)
+
+	// Approve returns when ctx is done.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = approver.Approve(ctx, testRequest(), 0)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, approver.pending)
+}
+
+func TestAnswerApprover(t *testing.T) {
+	cfg := testConfig()
+	args, err := parseArgs(process.Shell(`test "$PM_CREDS_PROFILE" = dev`))
+	assert.NoError(t, err)
+	cfg.approver = &commandApprover{args: args}
+
+	approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+	approved, err = cfg.approve(context.Background(), "prod", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+}
@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/process"
+)
+
+// authorizeTimeout is how long to wait for the user to authorize in the browser.
+var authorizeTimeout = 5 * time.Minute
+
+// callback is the result of an authorization received on the callback route.
+type callback struct {
+	code string
+	err  error
+}
+
+// callbacks contains the authorizations waiting for a callback by state.
+type callbacks struct {
+	mu      sync.Mutex
+	pending map[string]chan callback
+}
+
+// newCallbacks returns callbacks without any pending authorizations.
+func newCallbacks() *callbacks {
+	return &callbacks{pending: map[string]chan callback{}}
+}
+
+// wait will register state and return the channel the callback is delivered on.
+func (c *callbacks) wait(state string) chan callback {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan callback, 1)
+	c.pending[state] = ch
+	return ch
+}
+
+// done will remove state from pending authorizations.
+func (c *callbacks) done(state string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.pending, state)
+}
+
+// deliver will deliver result to the authorization waiting for state.
+// Returns false if there is no authorization waiting for state.
+func (c *callbacks) deliver(state string, result callback) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch, ok := c.pending[state]
+	if !ok {
+		return false
+	}
+	delete(c.pending, state)
+	ch <- result
+	return true
+}
+
+// CallbackURL returns the url of the callback route. It satisfies the
+// types.Authorizer interface and is used by providers.
+func (cfg *config) CallbackURL() string {
+	return fmt.Sprintf("https://%s/callback", fmt.Sprintf(listen, cfg.CallbackPort))
+}
+
+// Authorize will print authURL on the console, try to open it in the browser and wait for
+// the callback with state. It satisfies the types.Authorizer interface and is used by providers.
+func (cfg *config) Authorize(authURL string, state string) (string, error) {
+	ch := cfg.callbacks.wait(state)
+	defer cfg.callbacks.done(state)
+
+	cfg.logger.Warning("authorize in your browser within %s: %s%s", authorizeTimeout, authURL, logging.Lb())
+	go openBrowser(authURL)
+
+	select {
+	case result := <-ch:
+		return result.code, result.err
+	case <-time.After(authorizeTimeout):
+		return "", fmt.Errorf("server: no authorization received within %s", authorizeTimeout)
+	}
+}
+
+// callback is used to receive authorization codes from the browser.
+func (cfg *config) callback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	result := callback{code: query.Get("code")}
+	if msg := query.Get("error"); msg != "" {
+		if desc := query.Get("error_description"); desc != "" {
+			msg = fmt.Sprintf("%s: %s", msg, desc)
+		}
+		result.err = fmt.Errorf("server: authorization failed. %s", msg)
+	}
+	if result.code == "" && result.err == nil {
+		result.err = fmt.Errorf("server: no code in callback")
+	}
+
+	if !cfg.callbacks.deliver(query.Get("state"), result) {
+		write(w, 400, "text/plain", []byte("unknown or expired authorization"))
+		cfg.logger.Print("callback for unknown or expired authorization from %q%s", r.RemoteAddr, logging.Lb())
+		return
+	}
+
+	if result.err != nil {
+		write(w, 400, "text/plain", []byte(result.err.Error()))
+		cfg.logger.Warning("%s%s", result.err, logging.Lb())
+		return
+	}
+
+	write(w, 200, "text/plain", []byte("pm-creds: authorized, you can close this window."))
+	cfg.logger.Notice("received authorization from browser%s", logging.Lb())
+}
+
+// openBrowser will try to open url in the default browser.
+var openBrowser = func(url string) {
+	args := []string{"xdg-open", url}
+	switch runtime.GOOS {
+	case "darwin":
+		args = []string{"open", url}
+	case "windows":
+		args = []string{"rundll32", "url.dll,FileProtocolHandler", url}
+	}
+
+	cmd := &process.Command{Args: args, Timeout: 30 * time.Second}
+	cmd.Run()
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthorize(t *testing.T) {
+	opened := make(chan string, 1)
+	defer func(open func(string), timeout time.Duration) { openBrowser, authorizeTimeout = open, timeout }(openBrowser, authorizeTimeout)
+	openBrowser = func(url string) { opened <- url }
+
+	cfg := &config{Port: 9999, CallbackPort: 9997, callbacks: newCallbacks(), logger: logging.New()}
+	assert.Equal(t, "https://localhost:9997/callback", cfg.CallbackURL())
+
+	// Callback with code.
+	go func() {
+		assert.Equal(t, "https://auth.example.com/authorize?state=state", <-opened)
+		w := httptest.NewRecorder()
+		cfg.callback(w, httptest.NewRequest(http.MethodGet, "/callback?state=state&code=code", nil))
+		assert.Equal(t, 200, w.Code)
+	}()
+	code, err := cfg.Authorize("https://auth.example.com/authorize?state=state", "state")
+	assert.NoError(t, err)
+	assert.Equal(t, "code", code)
+
+	// Callback with error.
+	go func() {
+		<-opened
+		w := httptest.NewRecorder()
+		cfg.callback(w, httptest.NewRequest(http.MethodGet, "/callback?state=denied&error=access_denied&error_description=no", nil))
+		assert.Equal(t, 400, w.Code)
+	}()
+	_, err = cfg.Authorize("https://auth.example.com/authorize", "denied")
+	assert.EqualError(t, err, "server: authorization failed. access_denied: no")
+
+	// Callback for unknown state.
+	w := httptest.NewRecorder()
+	cfg.callback(w, httptest.NewRequest(http.MethodGet, "/callback?state=unknown&code=code", nil))
+	assert.Equal(t, 400, w.Code)
+
+	// No callback.
+	authorizeTimeout = 10 * time.Millisecond
+	_, err = cfg.Authorize("https://auth.example.com/authorize", "timeout")
+	assert.Error(t, err)
+	<-opened
+	w = httptest.NewRecorder()
+	cfg.callback(w, httptest.NewRequest(http.MethodGet, "/callback?state=timeout&code=code", nil))
+	assert.Equal(t, 400, w.Code)
+}
+
+func TestClientCertificateRequired(t *testing.T) {
+	cfg := &config{logger: logging.New()}
+
+	w := httptest.NewRecorder()
+	cfg.ServerHTTP(w, httptest.NewRequest(http.MethodPost, "/aws/dev", nil))
+	assert.Equal(t, 401, w.Code)
+	assert.Equal(t, "client certificate required", w.Body.String())
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+)
+
+// line is a line read from the console.
+type line struct {
+	text string
+	err  error
+}
+
+// lineReader reads lines from the console in the background so that waiting
+// for an answer can be cancelled. Reading starts on the first call to read.
+type lineReader struct {
+	r     *bufio.Reader
+	once  sync.Once
+	lines chan line
+}
+
+// newLineReader returns a new lineReader reading from r.
+func newLineReader(r io.Reader) *lineReader {
+	return &lineReader{r: bufio.NewReader(r), lines: make(chan line)}
+}
+
+// start will start reading lines in the background. Once reading fails the
+// error is returned for all following reads.
+func (l *lineReader) start() {
+	go func() {
+		for {
+			// Should work with \r on windows.
+			text, err := l.r.ReadString('\n')
+			l.lines <- line{text: strings.Replace(text, logging.Lb(), "", -1), err: err}
+			if err != nil {
+				for {
+					l.lines <- line{err: err}
+				}
+			}
+		}
+	}()
+}
+
+// drain will discard any lines entered since the last read. It's called before a prompt
+// is shown so that lines entered while no one was asking, for example a late answer to a
+// cancelled prompt, isn't used as the answer. consoleMu must be held by the caller.
+func (l *lineReader) drain() {
+	l.once.Do(l.start)
+
+	for {
+		select {
+		case ln := <-l.lines:
+			if ln.err == nil {
+				continue
+			}
+		default:
+		}
+		return
+	}
+}
+
+// read will return the next line without the line break. Returns the error of ctx if
+// it's done before a line is entered. consoleMu must be held by the caller.
+func (l *lineReader) read(ctx context.Context) (string, error) {
+	l.once.Do(l.start)
+
+	select {
+	case ln := <-l.lines:
+		return ln.text, ln.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,259 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+)
+
+// historySize is the number of recent requests shown in the dashboard.
+const historySize = 50
+
+// Results of credential requests shown in the dashboard.
+const (
+	resultApproved     = "approved"
+	resultDenied       = "denied"
+	resultRejected     = "denied by policy"
+	resultUnknown      = "unknown provider or profile"
+	resultTimeout      = "timed out"
+	resultDisconnected = "client disconnected"
+	resultRevoked      = "revoked"
+)
+
+// dashboardPage shows pending approvals, recent requests, active grants and providers.
+// The token is sent with every form so that other sites can't post to the dashboard.
+var dashboardPage = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>pm-creds</title>
+</head>
+<body>
+<h1>pm-creds</h1>
+
+<h2>Pending approvals</h2>
+{{- range .Pending}}
+<form method="post" action="/answer">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="id" value="{{.ID}}">
+<p>{{if .Warn}}<strong>{{.ProfileName}} ({{.ProviderName}})</strong>{{else}}{{.ProfileName}} ({{.ProviderName}}){{end}}
+for {{.Remote}} waiting since {{.Since.Format "15:04:05"}}</p>
+<button name="answer" value="y">Approve</button>
+<input name="duration" placeholder="15m" size="5">
+<button name="answer" value="duration">Approve for duration</button>
+<button name="answer" value="n">Deny</button>
+</form>
+{{- else}}
+<p>No pending approvals.</p>
+{{- end}}
+
+<h2>Active grants</h2>
+<table>
+<tr><th>Provider</th><th>Profile</th><th>Client</th><th>Expires</th><th></th></tr>
+{{- range .Grants}}
+<tr><td>{{.ProviderName}}</td><td>{{.ProfileName}}</td><td>{{.Client}}</td><td>{{.Expires.Format "15:04:05"}}</td>
+<td><form method="post" action="/revoke">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="provider" value="{{.ProviderName}}">
+<input type="hidden" name="profile" value="{{.ProfileName}}">
+<button>Revoke</button>
+</form></td></tr>
+{{- end}}
+</table>
+
+<h2>Recent requests</h2>
+<table>
+<tr><th>Time</th><th>Provider</th><th>Profile</th><th>Remote</th><th>Result</th></tr>
+{{- range .History}}
+<tr><td>{{.Time.Format "15:04:05"}}</td><td>{{.ProviderName}}</td><td>{{.ProfileName}}</td><td>{{.Remote}}</td><td>{{.Result}}</td></tr>
+{{- end}}
+</table>
+
+<h2>Providers</h2>
+<ul>
+{{- range .Providers}}
+<li>{{.}}</li>
+{{- end}}
+</ul>
+</body>
+</html>
+`))
+
+// event is a credential request shown in the dashboard.
+type event struct {
+	Time         time.Time
+	ProviderName string
+	ProfileName  string
+	Remote       string
+	Result       string
+}
+
+// history keeps the most recent credential requests.
+type history struct {
+	mu     sync.Mutex
+	events []event
+}
+
+// newHistory returns a new empty history.
+func newHistory() *history {
+	return &history{}
+}
+
+// add will add a request for profileName in providerName from remote with result.
+func (h *history) add(providerName string, profileName string, remote string, result string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.events = append(h.events, event{
+		Time:         time.Now(),
+		ProviderName: providerName,
+		ProfileName:  profileName,
+		Remote:       remote,
+		Result:       result,
+	})
+	if len(h.events) > historySize {
+		h.events = h.events[len(h.events)-historySize:]
+	}
+}
+
+// list returns the requests newest first.
+func (h *history) list() []event {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	list := make([]event, 0, len(h.events))
+	for i := len(h.events) - 1; i >= 0; i-- {
+		list = append(list, h.events[i])
+	}
+	return list
+}
+
+// dashboardGrant is a grant shown in the dashboard.
+type dashboardGrant struct {
+	ProviderName string
+	ProfileName  string
+	Client       string
+	Expires      time.Time
+}
+
+// dashboard serves the admin dashboard on its own listener. It doesn't require a client
+// certificate, instead all requests must include the token printed when it starts.
+type dashboard struct {
+	cfg   *config
+	token string
+}
+
+// newDashboard returns a new dashboard for cfg with a random token.
+func newDashboard(cfg *config) (*dashboard, error) {
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+	return &dashboard{cfg: cfg, token: token}, nil
+}
+
+// ServeHTTP serves the dashboard page and the answer and revoke forms.
+func (d *dashboard) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("X-Frame-Options", "DENY")
+	w.Header().Add("Content-Security-Policy", "default-src 'none'; form-action 'self'")
+
+	switch {
+	case r.Method == "GET" && r.URL.Path == "/":
+		if !validToken(r.URL.Query().Get("token"), d.token) {
+			write(w, 401, "text/plain", []byte("invalid token"))
+			d.cfg.logger.Print("invalid token for dashboard from %q%s", r.RemoteAddr, logging.Lb())
+			return
+		}
+		d.page(w)
+
+	case r.Method == "POST" && (r.URL.Path == "/answer" || r.URL.Path == "/revoke"):
+		// Answers must carry the token in the form and come from the dashboard itself.
+		origin := r.Header.Get("Origin")
+		if !validToken(r.PostFormValue("token"), d.token) || (origin != "" && origin != "https://"+r.Host) {
+			write(w, 401, "text/plain", []byte("invalid token"))
+			d.cfg.logger.Print("invalid token for dashboard from %q%s", r.RemoteAddr, logging.Lb())
+			return
+		}
+
+		var err error
+		switch r.URL.Path {
+		case "/answer":
+			err = d.answer(r.PostFormValue("id"), r.PostFormValue("answer"), r.PostFormValue("duration"))
+		case "/revoke":
+			d.revoke(r.PostFormValue("provider"), r.PostFormValue("profile"))
+		}
+		if err != nil {
+			write(w, 400, "text/plain", []byte(err.Error()))
+			return
+		}
+		http.Redirect(w, r, "/?token="+url.QueryEscape(d.token), http.StatusSeeOther)
+
+	default:
+		write(w, 404, "text/plain", []byte("not found"))
+	}
+}
+
+// page will write the dashboard page to w.
+func (d *dashboard) page(w http.ResponseWriter) {
+	grants := []dashboardGrant{}
+	for _, gr := range d.cfg.grants.list() {
+		grants = append(grants, dashboardGrant{ProviderName: gr.providerName, ProfileName: gr.profileName, Client: gr.client, Expires: gr.expires})
+	}
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	dashboardPage.Execute(w, map[string]interface{}{
+		"Token":     d.token,
+		"Pending":   d.cfg.queue.list(),
+		"Grants":    grants,
+		"History":   d.cfg.history.list(),
+		"Providers": d.cfg.providers.Names(),
+	})
+}
+
+// answer will answer the request with id. Requests waiting in the queue are answered directly.
+func (d *dashboard) answer(id string, answer string, duration string) error {
+	text, err := formAnswer(answer, duration)
+	if err != nil {
+		return err
+	}
+
+	req, queued, err := d.cfg.queue.respond(id, text)
+	if err != nil {
+		return err
+	}
+	if queued {
+		d.cfg.logger.Print("answered queued approval of %q (%s) %s in dashboard%s", req.ProfileName, req.ProviderName, req.Remote, logging.Lb())
+		d.cfg.queue.finish(req, d.cfg.decide(req, text))
+	}
+	return nil
+}
+
+// revoke will revoke all grants for profileName in providerName.
+func (d *dashboard) revoke(providerName string, profileName string) {
+	revoked := d.cfg.grants.revoke(providerName, profileName)
+	d.cfg.history.add(providerName, profileName, "dashboard", resultRevoked)
+	d.cfg.logger.Notice("revoked %d approvals for %q (%s) in dashboard%s", revoked, profileName, providerName, logging.Lb())
+}
+
+// formAnswer returns the answer text for the answer and duration posted in a form.
+func formAnswer(answer string, duration string) (string, error) {
+	switch answer {
+	case "y", "n":
+		return answer, nil
+	case "duration":
+		duration = strings.TrimSpace(duration)
+		if _, err := time.ParseDuration(duration); err != nil {
+			return "", fmt.Errorf("invalid duration %q", duration)
+		}
+		return "y" + duration, nil
+	}
+	return "", fmt.Errorf("invalid answer %q", answer)
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHistory(t *testing.T) {
+	h := newHistory()
+	for i := 0; i < historySize+5; i++ {
+		h.add("aws", fmt.Sprintf("profile-%d", i), "remote", resultApproved)
+	}
+
+	events := h.list()
+	assert.Len(t, events, historySize)
+	assert.Equal(t, fmt.Sprintf("profile-%d", historySize+4), events[0].ProfileName)
+	assert.Equal(t, "profile-5", events[historySize-1].ProfileName)
+}
+
+func TestDashboard(t *testing.T) {
+	_, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.ApprovalPerClient = true
+	cfg.providers, _ = providers.Load("./testdata")
+	d, err := newDashboard(cfg)
+	assert.NoError(t, err)
+
+	get := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?token="+url.QueryEscape(token), nil))
+		return w
+	}
+	post := func(path string, form url.Values, origin string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if origin != "" {
+			r.Header.Set("Origin", origin)
+		}
+		w := httptest.NewRecorder()
+		d.ServeHTTP(w, r)
+		return w
+	}
+
+	// The page requires the token.
+	assert.Equal(t, 401, get("").Code)
+	w := get(d.token)
+	assert.Equal(t, 200, w.Code)
+	assert.Contains(t, w.Body.String(), "<li>aws</li>")
+	assert.Contains(t, w.Body.String(), "No pending approvals.")
+
+	// The first request is asked in the console and the second waits in the queue.
+	results := map[string]chan bool{"first": make(chan bool), "second": make(chan bool)}
+	for _, client := range []string{"first", "second"} {
+		go func(client string) {
+			approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", client, prompt)
+			assert.NoError(t, err)
+			results[client] <- approved
+		}(client)
+		time.Sleep(20 * time.Millisecond)
+	}
+	assert.Len(t, cfg.queue.list(), 2)
+	assert.Contains(t, get(d.token).Body.String(), `name="id" value="2"`)
+
+	// Answers needs the token in the form and must come from the dashboard.
+	assert.Equal(t, 401, post("/answer", url.Values{"id": {"2"}, "answer": {"n"}}, "").Code)
+	assert.Equal(t, 401, post("/answer", url.Values{"token": {d.token}, "id": {"2"}, "answer": {"n"}}, "https://evil.example.com").Code)
+	assert.Equal(t, 400, post("/answer", url.Values{"token": {d.token}, "id": {"2"}, "answer": {"maybe"}}, "").Code)
+
+	// Queued requests are answered directly.
+	assert.Equal(t, 303, post("/answer", url.Values{"token": {d.token}, "id": {"2"}, "answer": {"n"}}, "").Code)
+	assert.False(t, <-results["second"])
+
+	// The request being asked in the console is answered instead of the console.
+	assert.Equal(t, 303, post("/answer", url.Values{"token": {d.token}, "id": {"1"}, "answer": {"duration"}, "duration": {"1h"}}, "https://example.com").Code)
+	assert.True(t, <-results["first"])
+	assert.Equal(t, 400, post("/answer", url.Values{"token": {d.token}, "id": {"1"}, "answer": {"y"}}, "").Code)
+
+	w = get(d.token)
+	assert.Contains(t, w.Body.String(), `name="profile" value="dev"`)
+
+	// Grants can be revoked.
+	assert.Equal(t, 303, post("/revoke", url.Values{"token": {d.token}, "provider": {"aws"}, "profile": {"dev"}}, "").Code)
+	assert.Empty(t, cfg.grants.list())
+	assert.Equal(t, resultRevoked, cfg.history.list()[0].Result)
+
+	assert.Equal(t, 404, post("/unknown", url.Values{"token": {d.token}}, "").Code)
+}
@@ -0,0 +1,172 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+)
+
+// grant is an approval for a profile in a provider that can be reused
+// until it expires or is revoked.
+type grant struct {
+	providerName string
+	profileName  string
+	client       string
+	expires      time.Time
+	timer        *time.Timer
+}
+
+// grants keeps track of all approvals that has been granted for a time window.
+type grants struct {
+	mu     sync.Mutex
+	grants map[string]*grant
+	logger *logging.Logger
+}
+
+// newGrants returns a new empty grants that logs to logger.
+func newGrants(logger *logging.Logger) *grants {
+	return &grants{
+		grants: map[string]*grant{},
+		logger: logger,
+	}
+}
+
+// add will grant approval for profileName in providerName for ttl. If client isn't empty
+// the grant will only be valid for that client. Any existing grant will be replaced.
+func (g *grants) add(providerName string, profileName string, client string, ttl time.Duration) time.Time {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	key := grantKey(providerName, profileName, client)
+	if old, ok := g.grants[key]; ok {
+		old.timer.Stop()
+	}
+
+	gr := &grant{
+		providerName: providerName,
+		profileName:  profileName,
+		client:       client,
+		expires:      time.Now().Add(ttl),
+	}
+	gr.timer = time.AfterFunc(ttl, func() { g.expire(key, gr) })
+	g.grants[key] = gr
+
+	return gr.expires
+}
+
+// valid returns the expiry time and true if there is a grant for profileName in providerName
+// for client. A grant without client is valid for all clients.
+func (g *grants) valid(providerName string, profileName string, client string) (time.Time, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, key := range []string{grantKey(providerName, profileName, ""), grantKey(providerName, profileName, client)} {
+		gr, ok := g.grants[key]
+		if ok && time.Now().Before(gr.expires) {
+			return gr.expires, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// revoke will remove all grants for profileName in providerName regardless of client.
+// Returns the number of grants revoked.
+func (g *grants) revoke(providerName string, profileName string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	revoked := 0
+	for key, gr := range g.grants {
+		if gr.providerName != providerName || gr.profileName != profileName {
+			continue
+		}
+		gr.timer.Stop()
+		delete(g.grants, key)
+		revoked++
+	}
+
+	return revoked
+}
+
+// list returns a copy of all grants that haven't expired sorted by expiry time.
+func (g *grants) list() []grant {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	list := []grant{}
+	for _, gr := range g.grants {
+		if time.Now().Before(gr.expires) {
+			list = append(list, grant{providerName: gr.providerName, profileName: gr.profileName, client: gr.client, expires: gr.expires})
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].expires.Before(list[j].expires) })
+
+	return list
+}
+
+// expire will remove gr from grants if it's still the grant stored under key.
+func (g *grants) expire(key string, gr *grant) {
+	g.mu.Lock()
+	current, ok := g.grants[key]
+	if !ok || current != gr {
+		g.mu.Unlock()
+		return
+	}
+	delete(g.grants, key)
+	g.mu.Unlock()
+
+	g.logger.Print("approval for %q (%s)%s has expired%s", gr.profileName, gr.providerName, forClient(gr.client), logging.Lb())
+}
+
+// grantKey returns the key used to store grants for profileName in providerName and client.
+func grantKey(providerName string, profileName string, client string) string {
+	return strings.Join([]string{providerName, profileName, client}, "\x00")
+}
+
+// forClient returns client formatted to be used in log messages.
+func forClient(client string) string {
+	if client == "" {
+		return ""
+	}
+	return fmt.Sprintf(" for %s", client)
+}
+
+// clientIdentity returns the identity of the client making request r. It's made up of
+// the common name of the client certificate and the user agent.
+func clientIdentity(r *http.Request) string {
+	return fmt.Sprintf("%q (%s)", commonName(r), r.UserAgent())
+}
+
+// commonName returns the common name of the client certificate of request r.
+func commonName(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		return r.TLS.PeerCertificates[0].Subject.CommonName
+	}
+	return ""
+}
+
+// parseAnswer parses the answer text from an approval prompt. An answer of "y" is approved
+// for ttl and "y<duration>", for example "y15m", is approved for duration.
+// Returns if the answer was an approval and for how long it should be granted.
+func parseAnswer(text string, ttl time.Duration) (bool, time.Duration, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	switch {
+	case text == "y":
+		return true, ttl, nil
+
+	case strings.HasPrefix(text, "y"):
+		duration, err := time.ParseDuration(strings.TrimPrefix(text, "y"))
+		if err != nil {
+			return false, 0, fmt.Errorf("couldn't parse approval duration %q. %w", text, err)
+		}
+		return true, duration, nil
+	}
+
+	return false, 0, nil
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+var answerTests = []struct {
+	text     string
+	ttl      time.Duration
+	approved bool
+	duration time.Duration
+	err      bool
+}{
+	{text: "y", approved: true},
+	{text: "Y", ttl: time.Minute, approved: true, duration: time.Minute},
+	{text: "y15m", ttl: time.Minute, approved: true, duration: 15 * time.Minute},
+	{text: " y1h30m ", approved: true, duration: 90 * time.Minute},
+	{text: "n", ttl: time.Minute},
+	{text: ""},
+	{text: "yes", err: true},
+}
+
+func TestParseAnswer(t *testing.T) {
+	for _, test := range answerTests {
+		approved, duration, err := parseAnswer(test.text, test.ttl)
+		switch test.err {
+		case true:
+			assert.Error(t, err)
+		case false:
+			assert.NoError(t, err)
+		}
+		assert.Equal(t, test.approved, approved, test.text)
+		assert.Equal(t, test.duration, duration, test.text)
+	}
+}
+
+func TestGrants(t *testing.T) {
+	g := newGrants(logging.New())
+
+	g.add("aws", "dev", "", time.Hour)
+	_, ok := g.valid("aws", "dev", "client")
+	assert.True(t, ok)
+	_, ok = g.valid("aws", "prod", "")
+	assert.False(t, ok)
+
+	g.add("aws", "prod", "client", time.Hour)
+	_, ok = g.valid("aws", "prod", "client")
+	assert.True(t, ok)
+	_, ok = g.valid("aws", "prod", "other")
+	assert.False(t, ok)
+
+	assert.Equal(t, 1, g.revoke("aws", "prod"))
+	_, ok = g.valid("aws", "prod", "client")
+	assert.False(t, ok)
+
+	g.add("aws", "short", "", 10*time.Millisecond)
+	time.Sleep(50 * time.Millisecond)
+	_, ok = g.valid("aws", "short", "")
+	assert.False(t, ok)
+	assert.Equal(t, 0, g.revoke("aws", "short"))
+}
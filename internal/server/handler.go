@@ -1,30 +1,45 @@
 package server
 
 import (
-	"bufio"
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/policy"
+	"github.com/nuttmeister/pm-creds/internal/providers/types"
+)
+
+const timeFormat = "15:04:05"
+
+// Errors returned by approve if the request wasn't answered.
+var (
+	errRequestTimeout = errors.New("server: timed out waiting for approval")
+	errClientGone     = errors.New("server: client disconnected while waiting for approval")
 )
 
 var (
 	in        = os.Stdin
-	console   = bufio.NewReader(in)
+	console   = newLineReader(in)
 	consoleMu = &sync.Mutex{}
 )
 
 // ServerHTTP is used to deliver credentials.
 func (cfg *config) ServerHTTP(w http.ResponseWriter, r *http.Request) {
-	consoleMu.Lock()
-	consoleMu.Unlock()
-
 	remote := fmt.Sprintf("%q (%s)", r.RemoteAddr, r.UserAgent())
 
-	if r.Method != "POST" {
+	if r.TLS == nil || len(r.TLS.VerifiedChains) == 0 {
+		write(w, 401, "text/plain", []byte("client certificate required"))
+		cfg.logger.Print("client certificate required for %s%s", remote, logging.Lb())
+		return
+	}
+
+	if r.Method != "POST" && r.Method != "DELETE" {
 		write(w, 400, "text/plain", []byte(fmt.Sprintf("method %q not allowed", r.Method)))
 		cfg.logger.Print("method %q not allowed for %s%s", r.Method, remote, logging.Lb())
 		return
@@ -38,68 +53,258 @@ func (cfg *config) ServerHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	providerName, profileName := path[0], path[1]
 
-	if match(profileName, cfg.Deny) {
+	if r.Method == "DELETE" {
+		revoked := cfg.grants.revoke(providerName, profileName)
+		cfg.history.add(providerName, profileName, remote, resultRevoked)
+		write(w, 200, "text/plain", []byte(fmt.Sprintf("revoked %d approvals for %q (%s)", revoked, profileName, providerName)))
+		cfg.logger.Notice("revoked %d approvals for %q (%s) by %s%s", revoked, profileName, providerName, remote, logging.Lb())
+		return
+	}
+
+	decision := cfg.policy.Evaluate(policy.Request{
+		Provider:  providerName,
+		Profile:   profileName,
+		Client:    commonName(r),
+		UserAgent: r.UserAgent(),
+		Time:      time.Now(),
+	})
+	if decision.Action == policy.Deny {
 		write(w, 400, "text/plain", []byte(fmt.Sprintf("profile %q has been denied", profileName)))
-		cfg.logger.Warning("profile %q has been denied for %s%s", profileName, remote, logging.Lb())
+		cfg.history.add(providerName, profileName, remote, resultRejected)
+		cfg.logger.Warning("profile %q has been denied%s for %s%s", profileName, byRule(decision), remote, logging.Lb())
 		return
 	}
 
 	provider, err := cfg.providers.Get(providerName)
 	if err != nil {
 		write(w, 400, "text/plain", []byte(fmt.Sprintf("no provider named %q", providerName)))
+		cfg.history.add(providerName, profileName, remote, resultUnknown)
 		cfg.logger.Print("no provider named %q for %s%s", providerName, remote, logging.Lb())
 		return
 	}
 
-	profile, err := provider.Get(profileName)
+	// auto-approve or ask for approval. The credentials are only retrieved once approved
+	// since providers can ask for mfa codes, run commands or open the browser.
+	approved, err := cfg.approve(r.Context(), profileName, providerName, remote, clientIdentity(r), decision)
+	switch {
+	case errors.Is(err, errClientGone):
+		cfg.history.add(providerName, profileName, remote, resultDisconnected)
+		cfg.logger.Print("client %s disconnected while waiting for approval of %q (%s)%s", remote, profileName, providerName, logging.Lb())
+		return
+
+	case errors.Is(err, errRequestTimeout):
+		write(w, 408, "text/plain", []byte(fmt.Sprintf("timed out waiting for approval to use %q (%s)", profileName, providerName)))
+		cfg.history.add(providerName, profileName, remote, resultTimeout)
+		cfg.logger.Warning("timed out waiting for approval of %q (%s) %s%s", profileName, providerName, remote, logging.Lb())
+		return
+
+	case !approved:
+		write(w, 401, "text/plain", []byte(fmt.Sprintf("authorization to use %q (%s) denied", profileName, providerName)))
+		cfg.history.add(providerName, profileName, remote, resultDenied)
+		cfg.logger.Warning("denied credentials for %q (%s) %s%s", profileName, providerName, remote, logging.Lb())
+		return
+	}
+
+	profile, err := getProfile(r.Context(), provider, profileName)
 	if err != nil {
 		write(w, 400, "text/plain", []byte(fmt.Sprintf("no profile %q in provider %q", profileName, providerName)))
-		cfg.logger.Print("no profile %q (%s) for %s%s", profileName, providerName, remote, logging.Lb())
+		cfg.history.add(providerName, profileName, remote, resultUnknown)
+		cfg.logger.Print("no profile %q (%s) for %s. %s%s", profileName, providerName, remote, err, logging.Lb())
 		return
 	}
 
-	// auto-approve or ask for approval.
-	switch cfg.approve(w, profileName, providerName, remote) {
-	case true:
-		write(w, 200, "application/json", profile.Payload())
+	write(w, 200, "application/json", profile.Payload())
+	cfg.history.add(providerName, profileName, remote, resultApproved)
+}
 
-	case false:
-		write(w, 401, "text/plain", []byte(fmt.Sprintf("authorization to use %q (%s) denied", profileName, providerName)))
-		cfg.logger.Warning("denied credentials for %q (%s) %s%s", profileName, providerName, remote, logging.Lb())
+// approve will use the policy decision to evaluate if the request should be automatically approved,
+// is covered by an earlier approval or ask for user approval through the approval queue. returns
+// true if request is approved, errRequestTimeout if no answer was given in time or errClientGone
+// if ctx is done before the answer.
+func (cfg *config) approve(ctx context.Context, profileName string, providerName string, remote string, client string, decision policy.Decision) (bool, error) {
+	if decision.Action == policy.Allow {
+		cfg.logger.Notice("auto-approved credentials for %q (%s)%s %s%s", profileName, providerName, byRule(decision), remote, logging.Lb())
+		return true, nil
+	}
+
+	if !cfg.ApprovalPerClient {
+		client = ""
+	}
+	if cfg.granted(profileName, providerName, remote, client) {
+		return true, nil
+	}
+
+	req, shared, ahead := cfg.queue.add(providerName, profileName, client, remote, decision.Action == policy.WarnPrompt)
+	switch {
+	case shared:
+		cfg.logger.Print("waiting for pending approval of %q (%s) for %s%s", profileName, providerName, remote, logging.Lb())
+	case ahead > 0:
+		cfg.logger.Print("queued approval of %q (%s) for %s, %d ahead in queue%s", profileName, providerName, remote, ahead, logging.Lb())
+	}
+
+	timer := time.NewTimer(cfg.requestTimeout)
+	defer timer.Stop()
+
+	select {
+	case <-req.done:
+		return req.approved, nil
+	case <-timer.C:
+		cfg.queue.leave(req)
+		return false, errRequestTimeout
+	case <-ctx.Done():
+		cfg.queue.leave(req)
+		return false, errClientGone
 	}
 }
 
-// approve will evaluate if the request should be automatically approved or ask for
-// user approval through the console. returns true if request is approved.
-func (cfg *config) approve(w http.ResponseWriter, profileName string, providerName string, remote string) bool {
-	switch match(profileName, cfg.AutoApprove) {
-	case false:
-		consoleMu.Lock()
-		defer consoleMu.Unlock()
+// answer will ask for approval of req through the approver, it's called by the approval
+// queue for one request at a time. waiting is the number of requests left in the queue.
+// The prompt is cancelled if all clients of req disconnects and denied automatically if
+// not answered within approval-timeout. returns true if req is approved.
+func (cfg *config) answer(req *Request, waiting int) bool {
+	// Another request might have been granted while waiting in the queue.
+	if cfg.granted(req.ProfileName, req.ProviderName, req.Remote, req.Client) {
+		return true
+	}
 
-		prompt := fmt.Sprintf("authorize credentials for %q (%s) %s? [y/n]: ", profileName, providerName, remote)
-		switch match(profileName, cfg.Warn) {
-		case true:
-			cfg.logger.Alert(prompt)
-		case false:
-			cfg.logger.Warning(prompt)
-		}
+	ctx, cancel := req.ctx, func() {}
+	if cfg.approvalTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, cfg.approvalTimeout)
+	}
+	defer cancel()
 
-		// Should work with \r on windows.
-		text, _ := console.ReadString('\n')
+	text, err := cfg.ask(ctx, req, waiting)
+	switch {
+	case req.ctx.Err() != nil:
+		cfg.logger.Print("cancelled approval of %q (%s) %s, no client is waiting%s", req.ProfileName, req.ProviderName, req.Remote, logging.Lb())
+		return false
+	case errors.Is(err, context.DeadlineExceeded):
+		cfg.logger.Warning(
+			"denied credentials for %q (%s) %s automatically, no answer within %s%s",
+			req.ProfileName, req.ProviderName, req.Remote, cfg.approvalTimeout, logging.Lb(),
+		)
+		return false
+	case err != nil:
+		cfg.logger.Warning("couldn't get approval for %q (%s) %s. %s%s", req.ProfileName, req.ProviderName, req.Remote, err, logging.Lb())
+		return false
+	}
+
+	return cfg.decide(req, text)
+}
 
-		if strings.ToLower(strings.Replace(text, logging.Lb(), "", -1)) != "y" {
-			return false
+// ask will ask the approver for an answer to req. If an answer is given in the dashboard
+// while waiting the approver is cancelled and the answer from the dashboard is used.
+func (cfg *config) ask(ctx context.Context, req *Request, waiting int) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	answered := make(chan string, 1)
+	go func() {
+		select {
+		case text := <-req.answers:
+			answered <- text
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
 
-		cfg.logger.Notice("approved credentials for %q (%s) %s%s", profileName, providerName, remote, logging.Lb())
-		return true
+	text, err := cfg.approver.Approve(ctx, req, waiting)
+	select {
+	case text := <-answered:
+		cfg.logger.Print("answered approval of %q (%s) %s in dashboard%s", req.ProfileName, req.ProviderName, req.Remote, logging.Lb())
+		return text, nil
+	default:
+		return text, err
+	}
+}
+
+// decide will approve req if text is an approval and grant it for a while if a duration
+// was given or approval-ttl is set. returns true if req is approved.
+func (cfg *config) decide(req *Request, text string) bool {
+	approved, ttl, err := parseAnswer(text, cfg.approvalTTL)
+	if err != nil {
+		cfg.logger.Warning("%s%s", err, logging.Lb())
+	}
+	if !approved {
+		return false
+	}
 
-	case true:
-		cfg.logger.Notice("auto-approved credentials for %q (%s) %s%s", profileName, providerName, remote, logging.Lb())
+	if ttl > 0 {
+		expires := cfg.grants.add(req.ProviderName, req.ProfileName, req.Client, ttl)
+		cfg.logger.Notice(
+			"approved credentials for %q (%s) %s until %s%s",
+			req.ProfileName, req.ProviderName, req.Remote, expires.Format(timeFormat), logging.Lb(),
+		)
 		return true
 	}
-	return false
+
+	cfg.logger.Notice("approved credentials for %q (%s) %s%s", req.ProfileName, req.ProviderName, req.Remote, logging.Lb())
+	return true
+}
+
+// granted returns true if there is an approval for profileName in providerName that
+// is still valid for client.
+func (cfg *config) granted(profileName string, providerName string, remote string, client string) bool {
+	expires, ok := cfg.grants.valid(providerName, profileName, client)
+	if !ok {
+		return false
+	}
+
+	cfg.logger.Notice(
+		"reusing approval for %q (%s) %s valid until %s%s",
+		profileName, providerName, remote, expires.Format(timeFormat), logging.Lb(),
+	)
+	return true
+}
+
+// Prompt will ask the user for input through the console and return the answer. The prompt
+// waits for its turn in the approval queue so it's never shown at the same time as an approval.
+// It's cancelled when ctx is done or request-timeout has passed, and when it's not answered
+// within approval-timeout. It satisfies the types.Prompter interface and is used by providers.
+func (cfg *config) Prompt(ctx context.Context, format string, a ...interface{}) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.requestTimeout)
+	defer cancel()
+
+	var text string
+	var err error
+	if queueErr := cfg.queue.run(ctx, func() {
+		ctx, cancel := ctx, func() {}
+		if cfg.approvalTimeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, cfg.approvalTimeout)
+		}
+		defer cancel()
+		if err = ctx.Err(); err != nil {
+			return
+		}
+
+		consoleMu.Lock()
+		defer consoleMu.Unlock()
+
+		console.drain()
+		cfg.logger.Warning(format, a...)
+		text, err = console.read(ctx)
+	}); queueErr != nil {
+		err = queueErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("server: couldn't read answer from console. %w", err)
+	}
+
+	return strings.TrimSpace(text), nil
+}
+
+// PromptSecret always returns an error since the console echoes all input. Secrets
+// such as passphrases must be set in the config instead when running the server.
+func (cfg *config) PromptSecret(ctx context.Context, format string, a ...interface{}) (string, error) {
+	return "", fmt.Errorf("server: secrets can't be entered in the console since it echoes input")
+}
+
+// getProfile will get profile name from provider, using ctx if the provider supports it.
+func getProfile(ctx context.Context, provider types.Provider, name string) (types.Profile, error) {
+	if getter, ok := provider.(types.ContextGetter); ok {
+		return getter.GetContext(ctx, name)
+	}
+	return provider.Get(name)
 }
 
 // write will write body to w with content-type ct and status code status.
@@ -109,16 +314,10 @@ func write(w http.ResponseWriter, status int, ct string, body []byte) {
 	w.Write(body)
 }
 
-// match will return true if str matches any of the patterns as either
-// prefix, suffix or whole match.
-func match(str string, patterns []string) bool {
-	for _, pattern := range patterns {
-		if strings.HasPrefix(str, pattern) {
-			return true
-		}
-		if strings.HasSuffix(str, pattern) {
-			return true
-		}
+// byRule returns the rule of decision formatted to be used in log messages.
+func byRule(decision policy.Decision) string {
+	if decision.Rule == "" {
+		return ""
 	}
-	return false
+	return fmt.Sprintf(" by rule %q", decision.Rule)
 }
@@ -0,0 +1,243 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Request is a pending approval for a profile in a provider. Concurrent requests
+// for the same profile, provider and client shares the same request and answer.
+type Request struct {
+	// ID identifies the request in the dashboard.
+	ID           string
+	ProviderName string
+	ProfileName  string
+	// Client is the identity of the client if approvals are per client.
+	Client string
+	// Remote is the address and user agent of the first client.
+	Remote string
+	// Warn is true if the policy decided to warn-prompt.
+	Warn bool
+	// Since is when the request was added to the queue.
+	Since time.Time
+
+	// answers receives answers given in the dashboard.
+	answers chan string
+
+	// run is set for prompts from providers, it's called by the worker instead of
+	// asking for approval. Prompts aren't shown in the dashboard.
+	run func()
+
+	// waiters is the number of http requests waiting for the answer. ctx is
+	// cancelled when there are no waiters left.
+	waiters  int
+	ctx      context.Context
+	cancel   context.CancelFunc
+	done     chan struct{}
+	approved bool
+}
+
+// queue serializes approvals and prompts from providers so that only one prompt is
+// shown at a time. Requests are answered in the order they were added by a single worker.
+type queue struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	pending map[string]*Request
+	order   []*Request
+	current *Request
+	seq     int
+}
+
+// newQueue returns a new empty queue and starts the worker that calls answer
+// for one request at a time. answer is called with the number of requests still
+// waiting in the queue and returns if the request was approved.
+func newQueue(answer func(req *Request, waiting int) bool) *queue {
+	q := &queue{pending: map[string]*Request{}}
+	q.cond = sync.NewCond(&q.mu)
+
+	go func() {
+		for {
+			req, waiting := q.next()
+			if req.run != nil {
+				req.run()
+				q.finish(req, false)
+				continue
+			}
+			q.finish(req, answer(req, waiting))
+		}
+	}()
+
+	return q
+}
+
+// add will add a request for profileName in providerName from client to the queue, or join the
+// pending request if there already is one. Returns the request, if it was shared and the number
+// of requests ahead of it in the queue.
+func (q *queue) add(providerName string, profileName string, client string, remote string, warn bool) (*Request, bool, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	key := grantKey(providerName, profileName, client)
+	if req, ok := q.pending[key]; ok {
+		req.waiters++
+		return req, true, 0
+	}
+
+	q.seq++
+	ctx, cancel := context.WithCancel(context.Background())
+	req := &Request{
+		ID:           strconv.Itoa(q.seq),
+		Since:        time.Now(),
+		answers:      make(chan string, 1),
+		ctx:          ctx,
+		cancel:       cancel,
+		ProviderName: providerName,
+		ProfileName:  profileName,
+		Client:       client,
+		Remote:       remote,
+		Warn:         warn,
+		waiters:      1,
+		done:         make(chan struct{}),
+	}
+	q.pending[key] = req
+	q.order = append(q.order, req)
+	q.cond.Signal()
+
+	return req, false, len(q.order) - 1
+}
+
+// run will add fn to the queue and wait until the worker has called it, so that prompts from
+// providers are never shown at the same time as approvals. If ctx is done before it's the turn
+// of fn it's skipped and the error of ctx returned. fn must return when ctx is done.
+func (q *queue) run(ctx context.Context, fn func()) error {
+	q.mu.Lock()
+	req := &Request{run: fn, waiters: 1, done: make(chan struct{}), cancel: func() {}}
+	q.order = append(q.order, req)
+	q.cond.Signal()
+	q.mu.Unlock()
+
+	select {
+	case <-req.done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	q.mu.Lock()
+	started := q.current == req
+	if !started {
+		req.waiters = 0
+	}
+	q.mu.Unlock()
+
+	if started {
+		<-req.done
+		return nil
+	}
+	return ctx.Err()
+}
+
+// leave is called when a http request stops waiting for req. Requests nobody is waiting
+// for are skipped by the worker, or cancelled if they are being answered.
+func (q *queue) leave(req *Request) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	req.waiters--
+	if req.waiters == 0 {
+		req.cancel()
+	}
+}
+
+// next will block until there is a request that someone is waiting for and remove it from
+// the queue. Returns the request and the number of requests still waiting.
+func (q *queue) next() (*Request, int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for {
+		for len(q.order) == 0 {
+			q.cond.Wait()
+		}
+
+		req := q.order[0]
+		q.order = q.order[1:]
+		if req.waiters > 0 {
+			q.current = req
+			return req, len(q.order)
+		}
+
+		q.remove(req)
+		close(req.done)
+		req.cancel()
+	}
+}
+
+// finish will set the answer of req and release everyone waiting for it.
+func (q *queue) finish(req *Request, approved bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current == req {
+		q.current = nil
+	}
+	req.approved = approved
+	q.remove(req)
+	close(req.done)
+	req.cancel()
+}
+
+// remove will remove req from the pending requests. q.mu must be held by the caller.
+func (q *queue) remove(req *Request) {
+	key := grantKey(req.ProviderName, req.ProfileName, req.Client)
+	if q.pending[key] == req {
+		delete(q.pending, key)
+	}
+}
+
+// list returns the approval being answered, if any, followed by the approvals waiting in
+// the queue that someone is still waiting for.
+func (q *queue) list() []*Request {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	reqs := []*Request{}
+	if q.current != nil && q.current.run == nil {
+		reqs = append(reqs, q.current)
+	}
+	for _, req := range q.order {
+		if req.waiters > 0 && req.run == nil {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs
+}
+
+// respond will give answer text to the request with id. The request being answered receives
+// it on its answers channel. A request waiting in the queue is removed from the queue and
+// returned with true, the caller must finish it.
+func (q *queue) respond(id string, text string) (*Request, bool, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.current != nil && q.current.run == nil && q.current.ID == id {
+		select {
+		case q.current.answers <- text:
+			return q.current, false, nil
+		default:
+			return nil, false, errors.New("request has already been answered")
+		}
+	}
+
+	for i, req := range q.order {
+		if req.ID != id || req.waiters == 0 || req.run != nil {
+			continue
+		}
+		q.order = append(q.order[:i:i], q.order[i+1:]...)
+		return req, true, nil
+	}
+
+	return nil, false, errors.New("unknown or expired request")
+}
@@ -0,0 +1,241 @@
+package server
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/policy"
+	"github.com/stretchr/testify/assert"
+)
+
+// testConsole replaces the console with a pipe that answers can be written to.
+func testConsole(t *testing.T) (*io.PipeWriter, func()) {
+	r, w := io.Pipe()
+	old := console
+	console = newLineReader(r)
+	return w, func() {
+		w.Close()
+		console = old
+	}
+}
+
+// answerLater writes text as an answer once the prompt has been shown.
+func answerLater(answers *io.PipeWriter, text string) {
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		answers.Write([]byte(text + "\n"))
+	}()
+}
+
+// prompt is the policy decision to ask for approval.
+var prompt = policy.Decision{Action: policy.Prompt}
+
+func testConfig() *config {
+	cfg := &config{grants: newGrants(logging.New()), history: newHistory(), logger: logging.New(), requestTimeout: time.Minute}
+	cfg.approver = &consoleApprover{logger: cfg.logger}
+	cfg.queue = newQueue(cfg.answer)
+	return cfg
+}
+
+func TestQueueDeduplicates(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+
+	// All requests for the same profile shares one answer.
+	wg := &sync.WaitGroup{}
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+			assert.NoError(t, err)
+			assert.True(t, approved)
+		}()
+	}
+	time.Sleep(50 * time.Millisecond)
+	answers.Write([]byte("y\n"))
+	wg.Wait()
+
+	// The next request is prompted again.
+	answerLater(answers, "n")
+	approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+}
+
+func TestQueueSerializes(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.ApprovalPerClient = true
+
+	results := make(chan string, 3)
+	for _, client := range []string{"first", "second", "third"} {
+		go func(client string) {
+			approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", client, prompt)
+			assert.NoError(t, err)
+			if approved {
+				results <- client
+			}
+		}(client)
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	// Answers are given to one prompt at a time in order.
+	answers.Write([]byte("y\n"))
+	assert.Equal(t, "first", <-results)
+	answers.Write([]byte("n\n"))
+	answers.Write([]byte("y\n"))
+	assert.Equal(t, "third", <-results)
+}
+
+func TestQueueTimeout(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.ApprovalPerClient = true
+	cfg.requestTimeout = 100 * time.Millisecond
+
+	// The first request is prompted and the second is queued, both times out.
+	first := make(chan error)
+	go func() {
+		_, err := cfg.approve(context.Background(), "dev", "aws", "remote", "first", prompt)
+		first <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	_, err := cfg.approve(context.Background(), "dev", "aws", "remote", "second", prompt)
+	assert.ErrorIs(t, err, errRequestTimeout)
+	assert.ErrorIs(t, <-first, errRequestTimeout)
+
+	// The prompt of the first request is cancelled and the second is skipped.
+	third := make(chan bool)
+	go func() {
+		approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "third", prompt)
+		assert.NoError(t, err)
+		third <- approved
+	}()
+	time.Sleep(20 * time.Millisecond)
+	answers.Write([]byte("y\n"))
+	assert.True(t, <-third)
+}
+
+func TestApprovalTimeout(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.approvalTimeout = 20 * time.Millisecond
+
+	approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.False(t, approved)
+
+	// A late answer isn't used for the next prompt.
+	go answers.Write([]byte("y\n"))
+	time.Sleep(20 * time.Millisecond)
+	cfg.approvalTimeout = 0
+	result := make(chan bool)
+	go func() {
+		approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+		assert.NoError(t, err)
+		result <- approved
+	}()
+	time.Sleep(20 * time.Millisecond)
+	answers.Write([]byte("n\n"))
+	assert.False(t, <-result)
+}
+
+func TestClientGone(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.ApprovalPerClient = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gone := make(chan error)
+	go func() {
+		_, err := cfg.approve(ctx, "dev", "aws", "remote", "gone", prompt)
+		gone <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-gone, errClientGone)
+
+	// The cancelled prompt doesn't take the answer of the next request.
+	result := make(chan bool)
+	go func() {
+		approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "next", prompt)
+		assert.NoError(t, err)
+		result <- approved
+	}()
+	time.Sleep(20 * time.Millisecond)
+	answers.Write([]byte("y\n"))
+	assert.True(t, <-result)
+}
+
+func TestQueueGranted(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+	cfg.ApprovalPerClient = true
+	cfg.approvalTTL = time.Hour
+
+	answerLater(answers, "y")
+	approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+
+	// Reuses the grant without asking.
+	approved, err = cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+	assert.NoError(t, err)
+	assert.True(t, approved)
+
+	approved, err = cfg.approve(context.Background(), "auto", "aws", "remote", "client", policy.Decision{Action: policy.Allow, Rule: "auto"})
+	assert.NoError(t, err)
+	assert.True(t, approved)
+}
+
+func TestQueuePrompt(t *testing.T) {
+	answers, restore := testConsole(t)
+	defer restore()
+	cfg := testConfig()
+
+	approval := make(chan bool)
+	go func() {
+		approved, err := cfg.approve(context.Background(), "dev", "aws", "remote", "client", prompt)
+		assert.NoError(t, err)
+		approval <- approved
+	}()
+	time.Sleep(20 * time.Millisecond)
+
+	// Prompts from providers waits for the approval being asked and isn't listed.
+	code := make(chan string)
+	go func() {
+		text, err := cfg.Prompt(context.Background(), "enter mfa code: ")
+		assert.NoError(t, err)
+		code <- text
+	}()
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, cfg.queue.list(), 1)
+
+	answers.Write([]byte("y\n"))
+	assert.True(t, <-approval)
+	answerLater(answers, "123456")
+	assert.Equal(t, "123456", <-code)
+	assert.Empty(t, cfg.queue.list())
+
+	// Prompts are cancelled with the request and time out like approvals.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cfg.Prompt(ctx, "enter mfa code: ")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	cfg.approvalTimeout = 10 * time.Millisecond
+	_, err = cfg.Prompt(context.Background(), "enter mfa code: ")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Empty(t, cfg.queue.list())
+}
@@ -5,31 +5,61 @@ import (
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/mitchellh/mapstructure"
 	"github.com/nuttmeister/pm-creds/internal/logging"
 	"github.com/nuttmeister/pm-creds/internal/paths"
+	"github.com/nuttmeister/pm-creds/internal/policy"
 	"github.com/nuttmeister/pm-creds/internal/providers"
 	"github.com/pelletier/go-toml"
 )
 
 const listen = "localhost:%d"
 
+// defaultRequestTimeout is how long a request waits for approval if not configured.
+const defaultRequestTimeout = 2 * time.Minute
+
+// defaultCallbackPort is the port of the callback and approvals routes if not configured.
+const defaultCallbackPort = 9997
+
 // config contains the basic configuration for the http server and it's handler.
 type config struct {
 	certificate   string
 	key           string
 	caCertificate string
 
-	Port int `mapstructure:"port"`
+	Port          int `mapstructure:"port"`
+	CallbackPort  int `mapstructure:"callback-port"`
+	DashboardPort int `mapstructure:"dashboard-port"`
 
 	AutoApprove []string `mapstructure:"profiles-approve"`
 	Warn        []string `mapstructure:"profiles-warn"`
 	Deny        []string `mapstructure:"profiles-deny"`
 
+	Policy        []policy.Rule `mapstructure:"policy"`
+	PolicyDefault string        `mapstructure:"policy-default"`
+	policy        *policy.Policy
+
+	ApprovalTTL       string   `mapstructure:"approval-ttl"`
+	ApprovalPerClient bool     `mapstructure:"approval-per-client"`
+	ApprovalTimeout   string   `mapstructure:"approval-timeout"`
+	RequestTimeout    string   `mapstructure:"request-timeout"`
+	Approver          string   `mapstructure:"approver"`
+	ApproverCommand   []string `mapstructure:"approver-command"`
+	approvalTTL       time.Duration
+	approvalTimeout   time.Duration
+	requestTimeout    time.Duration
+
 	providers *providers.Providers
+	grants    *grants
+	queue     *queue
+	approver  Approver
+	history   *history
+	callbacks *callbacks
 	logger    *logging.Logger
 }
 
@@ -40,22 +70,45 @@ func Start(cfgDir string, providers *providers.Providers, logger *logging.Logger
 		return fmt.Errorf("server: couldn't load config. %w", err)
 	}
 	cfg.providers = providers
+	cfg.grants = newGrants(logger)
+	cfg.history = newHistory()
+	cfg.callbacks = newCallbacks()
 	cfg.logger = logger
+	cfg.approver, err = newApprover(cfg.Approver, cfg.ApproverCommand, cfg)
+	if err != nil {
+		return fmt.Errorf("server: couldn't create approver. %w", err)
+	}
+	cfg.queue = newQueue(cfg.answer)
+	cfg.providers.SetPrompter(cfg)
+	authorizes := cfg.providers.SetAuthorizer(cfg)
 
 	ca, err := caPool(cfg.caCertificate)
 	if err != nil {
 		return fmt.Errorf("server: couldn't create ca pool. %w", err)
 	}
 
-	http.HandleFunc("/", cfg.ServerHTTP)
 	server := &http.Server{
-		Addr: fmt.Sprintf(listen, cfg.Port),
+		Addr:    fmt.Sprintf(listen, cfg.Port),
+		Handler: http.HandlerFunc(cfg.ServerHTTP),
 		TLSConfig: &tls.Config{
 			ClientCAs:  ca,
 			ClientAuth: tls.RequireAndVerifyClientCert,
 		},
 	}
 
+	// The callback listener is only needed to authorize in a browser or for the web approver.
+	if _, web := cfg.approver.(http.Handler); authorizes || web {
+		if err := cfg.startCallbacks(); err != nil {
+			return fmt.Errorf("server: couldn't start callbacks. %w", err)
+		}
+	}
+
+	if cfg.DashboardPort != 0 {
+		if err := cfg.startDashboard(); err != nil {
+			return fmt.Errorf("server: couldn't start dashboard. %w", err)
+		}
+	}
+
 	cfg.logger.Print("starting listening on https://%s%s", fmt.Sprintf(listen, cfg.Port), logging.Lb())
 	if err := server.ListenAndServeTLS(cfg.certificate, cfg.key); err != nil {
 		return fmt.Errorf("server: http server error. %w", err)
@@ -64,6 +117,59 @@ func Start(cfgDir string, providers *providers.Providers, logger *logging.Logger
 	return nil
 }
 
+// startCallbacks will start serving the callback and approvals routes on callback-port in the
+// background. They are reached by browsers so it doesn't ask for client certificates, which
+// are always required for credentials. Returns error if callback-port can't be listened on.
+func (cfg *config) startCallbacks() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/callback", cfg.callback)
+	if handler, ok := cfg.approver.(http.Handler); ok {
+		mux.Handle("/approvals", handler)
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf(listen, cfg.CallbackPort), Handler: mux}
+	ln, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+	go func() {
+		if err := server.ServeTLS(ln, cfg.certificate, cfg.key); err != nil {
+			cfg.logger.Error(fmt.Errorf("server: callback server error. %w", err))
+		}
+	}()
+
+	cfg.logger.Print("callbacks listening on https://%s%s", fmt.Sprintf(listen, cfg.CallbackPort), logging.Lb())
+	return nil
+}
+
+// startDashboard will start serving the dashboard on dashboard-port in the background.
+// It uses the server certificate but doesn't ask for client certificates.
+func (cfg *config) startDashboard() error {
+	d, err := newDashboard(cfg)
+	if err != nil {
+		return err
+	}
+
+	server := &http.Server{Addr: fmt.Sprintf(listen, cfg.DashboardPort), Handler: d}
+	go func() {
+		if err := server.ListenAndServeTLS(cfg.certificate, cfg.key); err != nil {
+			cfg.logger.Error(fmt.Errorf("server: dashboard server error. %w", err))
+		}
+	}()
+
+	cfg.logger.Print("dashboard listening on https://%s/?token=%s%s", fmt.Sprintf(listen, cfg.DashboardPort), d.token, logging.Lb())
+	return nil
+}
+
+// LoadPolicy will return the approval policy from the config file in cfgDir.
+func LoadPolicy(cfgDir string) (*policy.Policy, error) {
+	cfg, err := loadConfig(cfgDir)
+	if err != nil {
+		return nil, fmt.Errorf("server: couldn't load config. %w", err)
+	}
+	return cfg.policy, nil
+}
+
 // caPool will return a new cert pool only containing cert from fn.
 func caPool(fn string) (*x509.CertPool, error) {
 	raw, err := os.ReadFile(fn)
@@ -99,11 +205,48 @@ func loadConfig(cfgDir string) (*config, error) {
 		return nil, fmt.Errorf("couldn't toml unmarshal file %q. %w", fn, err)
 	}
 
-	cfg := &config{}
+	cfg := &config{CallbackPort: defaultCallbackPort, requestTimeout: defaultRequestTimeout}
 	if err := mapstructure.Decode(raw, cfg); err != nil {
 		return nil, fmt.Errorf("couldn't decode raw to config for %q. %w", fn, err)
 	}
 
+	if cfg.ApprovalTTL != "" {
+		ttl, err := time.ParseDuration(cfg.ApprovalTTL)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s in %q. %w", "approval-ttl", fn, err)
+		}
+		cfg.approvalTTL = ttl
+	}
+
+	if cfg.ApprovalTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.ApprovalTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s in %q. %w", "approval-timeout", fn, err)
+		}
+		cfg.approvalTimeout = timeout
+	}
+
+	if cfg.RequestTimeout != "" {
+		timeout, err := time.ParseDuration(cfg.RequestTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse %s in %q. %w", "request-timeout", fn, err)
+		}
+		cfg.requestTimeout = timeout
+	}
+
+	// The legacy profile lists are evaluated after the policy rules.
+	cfg.policy, err = policy.New(append(cfg.Policy, policy.Legacy(cfg.AutoApprove, cfg.Warn, cfg.Deny)...), cfg.PolicyDefault)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create policy from %q. %w", fn, err)
+	}
+
+	if cfg.DashboardPort != 0 && cfg.DashboardPort == cfg.Port {
+		return nil, fmt.Errorf("%s must be different from %s in %q", "dashboard-port", "port", fn)
+	}
+	if cfg.CallbackPort == cfg.Port || cfg.CallbackPort == cfg.DashboardPort {
+		return nil, fmt.Errorf("%s must be different from %s and %s in %q", "callback-port", "port", "dashboard-port", fn)
+	}
+
 	// Set certificates.
 	cfg.caCertificate = paths.CaCertFile(cfgDir)
 	cfg.key = paths.ServerKeyFile(cfgDir)
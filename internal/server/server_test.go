@@ -0,0 +1,123 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/policy"
+	"github.com/nuttmeister/pm-creds/internal/providers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadPolicy(t *testing.T) {
+	p, err := LoadPolicy("./testdata")
+	assert.NoError(t, err)
+
+	for name, test := range map[string]struct {
+		req      policy.Request
+		expected policy.Decision
+	}{
+		"rule":        {policy.Request{Provider: "aws", Profile: "dev", Client: "postman"}, policy.Decision{Action: policy.Allow, Rule: "postman-dev"}},
+		"other":       {policy.Request{Provider: "aws", Profile: "dev", Client: "curl"}, policy.Decision{Action: policy.Prompt}},
+		"regex":       {policy.Request{Provider: "aws", Profile: "sandbox-1"}, policy.Decision{Action: policy.Deny, Rule: "sandbox"}},
+		"legacy deny": {policy.Request{Provider: "aws", Profile: "root-dev"}, policy.Decision{Action: policy.Deny, Rule: "profiles-deny"}},
+		"legacy warn": {policy.Request{Provider: "aws", Profile: "team-prod"}, policy.Decision{Action: policy.WarnPrompt, Rule: "profiles-warn"}},
+	} {
+		assert.Equal(t, test.expected, p.Evaluate(test.req), name)
+	}
+
+	_, err = LoadPolicy("./missing")
+	assert.Error(t, err)
+}
+
+func TestLoadConfigPorts(t *testing.T) {
+	cfg, err := loadConfig("./testdata")
+	assert.NoError(t, err)
+	assert.Equal(t, defaultCallbackPort, cfg.CallbackPort)
+
+	for config, expected := range map[string]string{
+		"port = 9999\ncallback-port = 9998":                        "",
+		"port = 9999\ncallback-port = 9999":                        "callback-port must be different",
+		"port = 9999\ndashboard-port = 9997":                       "callback-port must be different",
+		"port = 9999\ndashboard-port = 9999":                       "dashboard-port must be different",
+		"port = 9999\ncallback-port = 9998\ndashboard-port = 9997": "",
+	} {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.toml"), []byte(config), 0600))
+		_, err := loadConfig(dir)
+		switch expected {
+		case "":
+			assert.NoError(t, err, config)
+		default:
+			assert.Error(t, err, config)
+			if err != nil {
+				assert.Contains(t, err.Error(), expected, config)
+			}
+		}
+	}
+}
+
+func TestStartCallbacks(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	assert.NoError(t, err)
+	defer ln.Close()
+
+	// Errors listening on callback-port are returned instead of only logged.
+	cfg := testConfig()
+	cfg.CallbackPort = ln.Addr().(*net.TCPAddr).Port
+	assert.Error(t, cfg.startCallbacks())
+}
+
+func TestServerPolicy(t *testing.T) {
+	defer os.Setenv("PM_CREDS_TEST_KEY", os.Getenv("PM_CREDS_TEST_KEY"))
+	os.Setenv("PM_CREDS_TEST_KEY", "secret")
+
+	cfg, err := loadConfig("./testdata")
+	assert.NoError(t, err)
+	cfg.providers, err = providers.Load("./testdata")
+	assert.NoError(t, err)
+	cfg.grants, cfg.history, cfg.logger = newGrants(logging.New()), newHistory(), logging.New()
+
+	request := func(path string, cn string) *httptest.ResponseRecorder {
+		cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+		r := httptest.NewRequest(http.MethodPost, path, nil)
+		r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+		w := httptest.NewRecorder()
+		cfg.ServerHTTP(w, r)
+		return w
+	}
+
+	// Allowed by rule for the client.
+	w := request("/aws/dev", "postman")
+	assert.Equal(t, 200, w.Code)
+	assert.JSONEq(t, `{"key":"secret"}`, w.Body.String())
+
+	// Denied before the provider is looked up.
+	w = request("/unknown/sandbox-1", "postman")
+	assert.Equal(t, 400, w.Code)
+	assert.Equal(t, `profile "sandbox-1" has been denied`, w.Body.String())
+	assert.Equal(t, resultRejected, cfg.history.list()[0].Result)
+
+	// Other clients are asked for approval before the profile is retrieved.
+	cfg.approver = &consoleApprover{logger: cfg.logger}
+	cfg.queue = newQueue(cfg.answer)
+	answers, restore := testConsole(t)
+	defer restore()
+	answerLater(answers, "n")
+	assert.Equal(t, 401, request("/aws/missing", "curl").Code)
+	answerLater(answers, "y")
+	assert.Equal(t, 400, request("/aws/missing", "curl").Code)
+
+	cfg.requestTimeout = 10 * time.Millisecond
+	assert.Equal(t, 408, request("/aws/dev", "curl").Code)
+	assert.Eventually(t, func() bool { return len(cfg.queue.list()) == 0 }, time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+)
+
+// approvalsPage lists the pending approvals. The token is sent with every form so that
+// other sites can't answer approvals through the browser.
+var approvalsPage = template.Must(template.New("approvals").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta http-equiv="refresh" content="5">
+<title>pm-creds approvals</title>
+</head>
+<body>
+<h1>pm-creds approvals</h1>
+{{- range .Pending}}
+<form method="post">
+<input type="hidden" name="token" value="{{$.Token}}">
+<input type="hidden" name="id" value="{{.ID}}">
+<p>{{if .Req.Warn}}<strong>{{.Message}}</strong>{{else}}{{.Message}}{{end}} (waiting since {{.Since.Format "15:04:05"}})</p>
+<button name="answer" value="y">Approve</button>
+<input name="duration" placeholder="15m" size="5">
+<button name="answer" value="duration">Approve for duration</button>
+<button name="answer" value="n">Deny</button>
+</form>
+{{- else}}
+<p>No pending approvals.</p>
+{{- end}}
+</body>
+</html>
+`))
+
+// webPending is an approval waiting for an answer in the browser.
+type webPending struct {
+	ID      string
+	Req     *Request
+	Message string
+	Since   time.Time
+	answer  chan string
+}
+
+// webApprover asks for approval on a page served by the server. The page requires
+// the token that is printed on the console when the server starts.
+type webApprover struct {
+	url    string
+	token  string
+	logger *logging.Logger
+
+	mu      sync.Mutex
+	pending map[string]*webPending
+}
+
+// newWebApprover returns a new webApprover for the page at url with a random token.
+func newWebApprover(logger *logging.Logger, url string) (*webApprover, error) {
+	token, err := randomID()
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Print("approve requests at %s?token=%s%s", url, token, logging.Lb())
+	return &webApprover{url: url, token: token, logger: logger, pending: map[string]*webPending{}}, nil
+}
+
+// Approve will add the request to the page and wait for the answer.
+func (a *webApprover) Approve(ctx context.Context, req *Request, waiting int) (string, error) {
+	id, err := randomID()
+	if err != nil {
+		return "", err
+	}
+	p := &webPending{ID: id, Req: req, Message: message(req), Since: time.Now(), answer: make(chan string, 1)}
+
+	a.mu.Lock()
+	a.pending[id] = p
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.pending, id)
+		a.mu.Unlock()
+	}()
+
+	prompt := fmt.Sprintf("%s approve at %s?token=%s%s", message(req), a.url, a.token, logging.Lb())
+	if waiting > 0 {
+		prompt = fmt.Sprintf("(%d more in queue) %s", waiting, prompt)
+	}
+	switch req.Warn {
+	case true:
+		a.logger.Alert(prompt)
+	case false:
+		a.logger.Warning(prompt)
+	}
+
+	select {
+	case answer := <-p.answer:
+		return answer, nil
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// ServeHTTP shows the pending approvals and receives the answers.
+func (a *webApprover) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		write(w, 400, "text/plain", []byte(fmt.Sprintf("method %q not allowed", r.Method)))
+		return
+	}
+
+	// Answers must carry the token in the form, not in the url.
+	token := r.URL.Query().Get("token")
+	if r.Method == "POST" {
+		token = r.PostFormValue("token")
+	}
+	if !validToken(token, a.token) {
+		write(w, 401, "text/plain", []byte("invalid token"))
+		a.logger.Print("invalid token for approvals from %q%s", r.RemoteAddr, logging.Lb())
+		return
+	}
+
+	if r.Method == "POST" {
+		if err := a.deliver(r.PostFormValue("id"), r.PostFormValue("answer"), r.PostFormValue("duration")); err != nil {
+			write(w, 400, "text/plain", []byte(err.Error()))
+			return
+		}
+		http.Redirect(w, r, fmt.Sprintf("%s?token=%s", r.URL.Path, url.QueryEscape(a.token)), http.StatusSeeOther)
+		return
+	}
+
+	a.mu.Lock()
+	pending := []*webPending{}
+	for _, p := range a.pending {
+		pending = append(pending, p)
+	}
+	a.mu.Unlock()
+	sort.Slice(pending, func(i, j int) bool { return pending[i].Since.Before(pending[j].Since) })
+
+	w.Header().Add("Content-Type", "text/html; charset=utf-8")
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("X-Frame-Options", "DENY")
+	approvalsPage.Execute(w, map[string]interface{}{"Token": a.token, "Pending": pending})
+}
+
+// deliver will send answer to the pending approval id. duration is used when the
+// answer is "duration".
+func (a *webApprover) deliver(id string, answer string, duration string) error {
+	text, err := formAnswer(answer, duration)
+	if err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	p, ok := a.pending[id]
+	if !ok {
+		return fmt.Errorf("unknown or expired approval")
+	}
+	delete(a.pending, id)
+	p.answer <- text
+	return nil
+}
+
+// randomID returns 16 random bytes hex encoded.
+func randomID() (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("server: couldn't create random value. %w", err)
+	}
+	return hex.EncodeToString(raw), nil
+}
+
+// validToken returns true if token is expected, compared in constant time.
+func validToken(token string, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -30,11 +31,26 @@ func main() {
 		os.Exit(0)
 	}
 
+	// Testing the policy only needs config.toml so it's done before providers are loaded.
+	switch flag.Arg(0) {
+	case "", "secrets":
+	case "policy":
+		testPolicy(flag.Args()[1:])
+		os.Exit(0)
+	default:
+		logger.Error(fmt.Errorf("unknown command %q", flag.Arg(0)))
+	}
+
 	providers, err := providers.Load(cfgDir)
 	if err != nil {
 		logger.Error(err)
 	}
 
+	if flag.Arg(0) == "secrets" {
+		manageSecrets(providers, flag.Args()[1:])
+		os.Exit(0)
+	}
+
 	if err := server.Start(cfgDir, providers, logger); err != nil {
 		logger.Error(err)
 	}
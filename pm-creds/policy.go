@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/policy"
+	"github.com/nuttmeister/pm-creds/internal/server"
+)
+
+// policyUsage is printed when the policy command is used incorrectly.
+const policyUsage = `usage: pm-creds policy test [--client name] [--user-agent agent] [--time 15:04] <provider> <profile>`
+
+// testPolicy will run the policy command args and explain which rule decides
+// the action for a request for profile in provider.
+func testPolicy(args []string) {
+	if len(args) < 1 || args[0] != "test" {
+		logger.Error(fmt.Errorf(policyUsage))
+	}
+
+	req := policy.Request{Time: time.Now()}
+	at := ""
+	flags := flag.NewFlagSet("policy test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.StringVar(&req.Client, "client", "", "Common name of the client certificate")
+	flags.StringVar(&req.UserAgent, "user-agent", "", "User agent of the client")
+	flags.StringVar(&at, "time", "", "Time of day of the request")
+	if err := flags.Parse(args[1:]); err != nil || flags.NArg() != 2 {
+		logger.Error(fmt.Errorf(policyUsage))
+	}
+	req.Provider, req.Profile = flags.Arg(0), flags.Arg(1)
+
+	if at != "" {
+		t, err := time.Parse("15:04", at)
+		if err != nil {
+			logger.Error(fmt.Errorf("couldn't parse time %q. %w", at, err))
+		}
+		req.Time = t
+	}
+
+	p, err := server.LoadPolicy(cfgDir)
+	if err != nil {
+		logger.Error(err)
+	}
+
+	steps, decision := p.Explain(req)
+	for _, step := range steps {
+		if step.Reason != "" {
+			logger.Print("rule %q (%s) doesn't match: %s%s", step.Rule, step.Action, step.Reason, logging.Lb())
+			continue
+		}
+		logger.Print("rule %q (%s) matches%s", step.Rule, step.Action, logging.Lb())
+	}
+
+	if decision.Rule == "" {
+		logger.Print("no rule matches, using policy-default %s for %q (%s)%s", decision.Action, req.Profile, req.Provider, logging.Lb())
+		return
+	}
+	logger.Print("%s for %q (%s) by rule %q%s", decision.Action, req.Profile, req.Provider, decision.Rule, logging.Lb())
+}
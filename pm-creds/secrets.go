@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/nuttmeister/pm-creds/internal/logging"
+	"github.com/nuttmeister/pm-creds/internal/providers"
+	"github.com/nuttmeister/pm-creds/internal/providers/secrets"
+	"golang.org/x/term"
+)
+
+// secretsUsage is printed when the secrets command is used incorrectly.
+const secretsUsage = `usage: pm-creds secrets list <provider>
+       pm-creds secrets add <provider> <profile> key=value...
+       pm-creds secrets rotate <provider> <profile> key=value...
+       pm-creds secrets remove <provider> <profile>`
+
+// manageSecrets will run the secrets command args against the file provider
+// in providers. Values are never printed, only profile and field names.
+func manageSecrets(providers *providers.Providers, args []string) {
+	if len(args) < 2 {
+		logger.Error(fmt.Errorf(secretsUsage))
+	}
+
+	provider, err := providers.Unwrap(args[1])
+	if err != nil {
+		logger.Error(err)
+	}
+	file, ok := provider.(*secrets.Provider)
+	if !ok {
+		logger.Error(fmt.Errorf("provider %q isn't of type %q", args[1], "file"))
+	}
+	file.SetPrompter(&terminal{})
+
+	store, err := file.Open()
+	if err != nil {
+		logger.Error(err)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 2:
+		for _, profile := range store.Profiles() {
+			logger.Print("%s: %s%s", profile, strings.Join(store.Fields(profile), ", "), logging.Lb())
+		}
+		return
+
+	case args[0] == "add" && len(args) > 3:
+		err = store.Add(args[2], parseFields(args[3:]))
+	case args[0] == "rotate" && len(args) > 3:
+		err = store.Rotate(args[2], parseFields(args[3:]))
+	case args[0] == "remove" && len(args) == 3:
+		err = store.Remove(args[2])
+	default:
+		logger.Error(fmt.Errorf(secretsUsage))
+	}
+	if err != nil {
+		logger.Error(err)
+	}
+
+	if err := store.Save(); err != nil {
+		logger.Error(err)
+	}
+	done := map[string]string{"add": "added", "rotate": "rotated", "remove": "removed"}
+	logger.Print("%s profile %q in %q%s", done[args[0]], args[2], file.File(), logging.Lb())
+}
+
+// parseFields will parse args in format key=value. A value of - is read from stdin.
+func parseFields(args []string) map[string]interface{} {
+	fields := map[string]interface{}{}
+	for _, arg := range args {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			logger.Error(fmt.Errorf("field %q isn't in format key=value", arg))
+		}
+
+		if kv[1] == "-" {
+			value, err := (&terminal{}).Prompt(context.Background(), "enter value for %q: ", kv[0])
+			if err != nil {
+				logger.Error(err)
+			}
+			kv[1] = value
+		}
+		fields[kv[0]] = kv[1]
+	}
+
+	return fields
+}
+
+// stdin is used to read input when stdin isn't a terminal.
+var stdin = bufio.NewReader(os.Stdin)
+
+// terminal satisfies the types.Prompter interface and asks the user for input
+// without echoing it when stdin is a terminal.
+type terminal struct{}
+
+// PromptSecret is the same as Prompt since input is never echoed.
+func (t *terminal) PromptSecret(ctx context.Context, format string, a ...interface{}) (string, error) {
+	return t.Prompt(ctx, format, a...)
+}
+
+// Prompt will print the prompt to stderr and return the line entered by the user.
+// ctx isn't used since the terminal is only read from by the secrets command.
+func (t *terminal) Prompt(ctx context.Context, format string, a ...interface{}) (string, error) {
+	fmt.Fprintf(os.Stderr, format, a...)
+
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		raw, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return strings.TrimSpace(string(raw)), err
+	}
+
+	text, err := stdin.ReadString('\n')
+	if err != nil && text == "" {
+		return "", err
+	}
+	return strings.TrimSpace(text), nil
+}
@@ -3,17 +3,37 @@ if (!profile) {
     throw new Error("'aws_profile' variable not set")
 }
 
+// Reuse credentials that expires until shortly before they expire.
+const expiration = Date.parse(pm.environment.get("aws_expiration"))
+if (pm.environment.get("aws_credentials_profile") === profile && expiration - 60000 > Date.now()) {
+    console.log(`using aws credentials from '${profile}' valid until ${pm.environment.get("aws_expiration")}`)
+    return
+}
+
 pm.sendRequest({
     url: `https://localhost:9999/aws/${profile}`,
     method: "POST",
     }, function (_, response) {
         if (response.status == "OK") {
             const body = response.json()
-            pm.variables.set("aws_access_key_id", body.accessKey)
-            pm.variables.set("aws_secret_access_key", body.secretKey)
+
+            // Remove credentials from an earlier session profile so that static keys
+            // aren't used together with an old session token.
+            if (!body.sessionToken || !body.expiration) {
+                pm.environment.unset("aws_access_key_id")
+                pm.environment.unset("aws_secret_access_key")
+                pm.environment.unset("aws_session_token")
+            }
+
+            // Credentials that expires are stored in the environment so they can be reused.
+            const vars = body.expiration ? pm.environment : pm.variables
+            vars.set("aws_access_key_id", body.accessKey)
+            vars.set("aws_secret_access_key", body.secretKey)
             if (body.sessionToken) {
-                pm.variables.set("aws_session_token", body.sessionToken)
+                vars.set("aws_session_token", body.sessionToken)
             }
+            pm.environment.set("aws_credentials_profile", profile)
+            pm.environment.set("aws_expiration", body.expiration || "")
             console.log(`using aws credentials from '${profile}'`)
             return
         } else {
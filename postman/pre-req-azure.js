@@ -0,0 +1,30 @@
+const profile = pm.environment.get("azure_profile")
+if (!profile) {
+    throw new Error("'azure_profile' variable not set")
+}
+
+// Reuse the token until shortly before it expires.
+const expiration = Date.parse(pm.environment.get("azure_expiration"))
+if (pm.environment.get("azure_token_profile") === profile && expiration - 60000 > Date.now()) {
+    console.log(`using azure token from '${profile}' valid until ${pm.environment.get("azure_expiration")}`)
+    return
+}
+
+pm.sendRequest({
+    url: `https://localhost:9999/azure/${profile}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+
+            pm.environment.set("azure_access_token", body.accessToken)
+            pm.environment.set("azure_tenant_id", body.tenantId)
+            pm.environment.set("azure_token_profile", profile)
+            pm.environment.set("azure_expiration", body.expiration || "")
+            console.log(`using azure token from '${profile}'`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching azure token")
+        }
+    }
+)
@@ -0,0 +1,26 @@
+const provider = pm.environment.get("docker_provider") || "docker"
+const registry = pm.environment.get("docker_registry")
+if (!registry) {
+    throw new Error("'docker_registry' variable not set")
+}
+
+const key = `${provider}/${registry}`
+pm.sendRequest({
+    url: `https://localhost:9999/${provider}/${encodeURIComponent(registry)}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+            if (body.identityToken) {
+                pm.variables.set("docker_identity_token", body.identityToken)
+            } else {
+                pm.variables.set("docker_username", body.username)
+                pm.variables.set("docker_password", body.password)
+            }
+            console.log(`using registry credentials from '${key}'`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching registry credentials")
+        }
+    }
+)
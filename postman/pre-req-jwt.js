@@ -0,0 +1,22 @@
+const provider = pm.environment.get("jwt_provider") || "jwt"
+const profile = pm.environment.get("jwt_profile")
+if (!profile) {
+    throw new Error("'jwt_profile' variable not set")
+}
+
+// A new token is minted for every request.
+const key = `${provider}/${profile}`
+pm.sendRequest({
+    url: `https://localhost:9999/${provider}/${encodeURIComponent(profile)}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+            pm.variables.set("jwt_token", body.token)
+            console.log(`using jwt from '${key}' valid until ${body.expiration}`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching jwt")
+        }
+    }
+)
@@ -0,0 +1,37 @@
+const provider = pm.environment.get("kube_provider") || "kubeconfig"
+const context = pm.environment.get("kube_context")
+if (!context) {
+    throw new Error("'kube_context' variable not set")
+}
+
+// Reuse tokens that expires until shortly before they expire.
+const key = `${provider}/${context}`
+const expiration = Date.parse(pm.environment.get("kube_expiration"))
+if (pm.environment.get("kube_token_context") === key && expiration - 60000 > Date.now()) {
+    console.log(`using kubernetes token from '${key}' valid until ${pm.environment.get("kube_expiration")}`)
+    return
+}
+
+pm.sendRequest({
+    url: `https://localhost:9999/${provider}/${encodeURIComponent(context)}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+            if (!body.token) {
+                throw new Error(`context '${context}' has no token, configure the client certificate in the postman settings`)
+            }
+
+            // Tokens that expires are stored in the environment so they can be reused.
+            const vars = body.expiration ? pm.environment : pm.variables
+            vars.set("kube_token", body.token)
+            pm.environment.set("kube_server", body.server)
+            pm.environment.set("kube_token_context", key)
+            pm.environment.set("kube_expiration", body.expiration || "")
+            console.log(`using kubernetes token from '${key}'`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching kubernetes token")
+        }
+    }
+)
@@ -0,0 +1,21 @@
+const provider = pm.environment.get("netrc_provider") || "netrc"
+const machine = pm.environment.get("netrc_machine")
+if (!machine) {
+    throw new Error("'netrc_machine' variable not set")
+}
+
+const key = `${provider}/${machine}`
+pm.sendRequest({
+    url: `https://localhost:9999/${provider}/${encodeURIComponent(machine)}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+            pm.request.headers.upsert({ key: "Authorization", value: body.authorization })
+            console.log(`using basic auth credentials from '${key}'`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching basic auth credentials")
+        }
+    }
+)
@@ -0,0 +1,33 @@
+const provider = pm.environment.get("oauth2_provider") || "oauth2"
+const profile = pm.environment.get("oauth2_profile")
+if (!profile) {
+    throw new Error("'oauth2_profile' variable not set")
+}
+
+// Reuse the token until shortly before it expires.
+const key = `${provider}/${profile}`
+const expiration = Date.parse(pm.environment.get("oauth2_expiration"))
+if (pm.environment.get("oauth2_token_profile") === key && expiration - 60000 > Date.now()) {
+    console.log(`using oauth2 token from '${key}' valid until ${pm.environment.get("oauth2_expiration")}`)
+    return
+}
+
+pm.sendRequest({
+    url: `https://localhost:9999/${provider}/${encodeURIComponent(profile)}`,
+    method: "POST",
+    }, function (_, response) {
+        if (response.status == "OK") {
+            const body = response.json()
+
+            // Tokens that expires are stored in the environment so they can be reused.
+            const vars = body.expiration ? pm.environment : pm.variables
+            vars.set("oauth2_access_token", body.accessToken)
+            pm.environment.set("oauth2_token_profile", key)
+            pm.environment.set("oauth2_expiration", body.expiration || "")
+            console.log(`using oauth2 token from '${key}'`)
+            return
+        } else {
+            throw new Error(response.text() || "unknown error fetching oauth2 token")
+        }
+    }
+)